@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+var errNoSuchUser = errors.New("no user with that email")
+
+func (cfg *apiConfig) handlerSetUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Role string `json:"role"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	if err := auth.ValidateRole(req.Role); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	u, err := cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: req.Role,
+	})
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find user", err)
+		return
+	}
+	// Access tokens carry the role, so the old ones have to go.
+	if err = cfg.revocations.revokeUser(r.Context(), u.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking tokens", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, User{
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		IsChirpyRed:   u.IsChirpyRed,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+		Handle:        u.Handle,
+		DisplayName:   u.DisplayName,
+		Bio:           u.Bio,
+		AvatarURL:     u.AvatarUrl,
+		AvatarMediaID: uuidOrNil(u.AvatarMediaID),
+	})
+}
+
+// handlerSignOutUser ends every session of a user and revokes their access
+// tokens, so a ban or a compromised account takes effect immediately.
+func (cfg *apiConfig) handlerSignOutUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	if _, err = cfg.db.GetUserByID(r.Context(), userID); err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find user", err)
+		return
+	}
+	if err = cfg.signOutEverywhere(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// makeAdmin promotes an existing account to admin. It backs the -make-admin
+// flag, which is how the first admin gets created.
+func makeAdmin(ctx context.Context, db *database.Queries, email string) error {
+	updated, err := db.SetUserRoleByEmail(ctx, database.SetUserRoleByEmailParams{
+		Email: email,
+		Role:  auth.RoleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errNoSuchUser
+	}
+	return nil
+}
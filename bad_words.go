@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/profanity"
+)
+
+type BadWord struct {
+	Word      string    `json:"word"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type FlaggedChirp struct {
+	Chirp
+	FlaggedWords []string `json:"flagged_words"`
+}
+
+// loadProfanityFilter reads the word list for every chirp rather than caching
+// it, so changes made through the admin endpoints apply straight away.
+func (cfg *apiConfig) loadProfanityFilter(ctx context.Context) (profanity.Filter, error) {
+	rows, err := cfg.db.GetBadWords(ctx)
+	if err != nil {
+		return profanity.Filter{}, err
+	}
+	words := map[string]profanity.Action{}
+	for _, row := range rows {
+		words[row.Word] = profanity.Action(row.Action)
+	}
+	return profanity.NewFilter(words), nil
+}
+
+// flagChirp records the words that got a chirp flagged for review. A chirp
+// without any, say because an edit took them out, is taken off the list.
+func flagChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID, words []string) error {
+	if len(words) == 0 {
+		return q.UnflagChirp(ctx, chirpID)
+	}
+	return q.FlagChirp(ctx, database.FlagChirpParams{
+		ChirpID: chirpID,
+		Words:   words,
+	})
+}
+
+func (cfg *apiConfig) handlerGetBadWords(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.db.GetBadWords(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving words", err)
+		return
+	}
+	response := []BadWord{}
+	for _, row := range rows {
+		response = append(response, BadWord{
+			Word:      row.Word,
+			Action:    row.Action,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerSetBadWord adds a word to the filter, or changes the action of one
+// that is already there.
+func (cfg *apiConfig) handlerSetBadWord(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Word   string `json:"word"`
+		Action string `json:"action"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	word := strings.TrimSpace(req.Word)
+	if !profanity.IsWord(word) {
+		respondError(w, http.StatusBadRequest, "word must be a single word without spaces or punctuation", nil)
+		return
+	}
+	if req.Action == "" {
+		req.Action = string(profanity.Mask)
+	}
+	action, err := profanity.ParseAction(req.Action)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	row, err := cfg.db.SetBadWord(r.Context(), database.SetBadWordParams{
+		Word:   profanity.Fold(word),
+		Action: string(action),
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving word", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, BadWord{
+		Word:      row.Word,
+		Action:    row.Action,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	})
+}
+
+func (cfg *apiConfig) handlerDeleteBadWord(w http.ResponseWriter, r *http.Request) {
+	deleted, err := cfg.db.DeleteBadWord(r.Context(), profanity.Fold(r.PathValue("word")))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error deleting word", err)
+		return
+	}
+	if deleted == 0 {
+		respondError(w, http.StatusNotFound, "Word is not in the filter", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetFlaggedChirps(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.db.GetFlaggedChirps(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving flagged Chirps", err)
+		return
+	}
+	response := []FlaggedChirp{}
+	for _, row := range rows {
+		response = append(response, FlaggedChirp{
+			Chirp: toChirp(database.Chirp{
+				ID:        row.ID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				Body:      row.Body,
+				UserID:    row.UserID,
+				ParentID:  row.ParentID,
+				RechirpOf: row.RechirpOf,
+			}),
+			FlaggedWords: row.Words,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
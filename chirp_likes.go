@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+// viewerFromContext returns the user making the request on routes where
+// authentication is optional.
+func viewerFromContext(ctx context.Context) uuid.NullUUID {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	if _, err := cfg.db.GetChirpByID(r.Context(), chirpID); err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find Chirp", err)
+		return
+	}
+	if err := cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error liking Chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	if err := cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error unliking Chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+// handlerGetChirpReplies lists the direct replies to a chirp, oldest first.
+// Replies to a reply can be fetched the same way using its own ID. When a
+// chirp is deleted its replies stay up but lose their parent_id.
+func (cfg *apiConfig) handlerGetChirpReplies(w http.ResponseWriter, r *http.Request) {
+	viewerID := viewerFromContext(r.Context())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if _, err := cfg.db.GetChirpByID(r.Context(), chirpID); err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find Chirp", err)
+		return
+	}
+	replies, err := cfg.db.GetChirpReplies(r.Context(), database.GetChirpRepliesParams{
+		ParentID:        uuid.NullUUID{UUID: chirpID, Valid: true},
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving replies", err)
+		return
+	}
+	replies = page.trim(w, r, replies)
+	response, err := cfg.toChirps(r.Context(), replies, viewerID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving replies", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Body      string    `json:"body"`
+}
+
+func (cfg *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	if _, err := cfg.db.GetChirpByID(r.Context(), chirpID); err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find Chirp", err)
+		return
+	}
+	revisions, err := cfg.db.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving revisions", err)
+		return
+	}
+	response := []ChirpRevision{}
+	for _, revision := range revisions {
+		response = append(response, ChirpRevision{
+			ID:        revision.ID,
+			CreatedAt: revision.CreatedAt,
+			ChirpID:   revision.ChirpID,
+			Body:      revision.Body,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -11,90 +12,307 @@ import (
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Body         string     `json:"body"`
+	UserID       uuid.UUID  `json:"user_id"`
+	Author       *Author    `json:"author"`
+	ParentID     *uuid.UUID `json:"parent_id"`
+	ReplyCount   int64      `json:"reply_count"`
+	LikeCount    int64      `json:"like_count"`
+	LikedByMe    *bool      `json:"liked_by_me,omitempty"`
+	RechirpOf    *uuid.UUID `json:"rechirp_of"`
+	Rechirp      *Chirp     `json:"rechirp,omitempty"`
+	RechirpCount int64      `json:"rechirp_count"`
+	Entities     Entities   `json:"entities"`
+	Media        []Media    `json:"media"`
+}
+
+func toChirp(chirp database.Chirp) Chirp {
+	response := Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		Entities:  Entities{Hashtags: []Hashtag{}, Mentions: []Mention{}},
+		Media:     []Media{},
+	}
+	if chirp.ParentID.Valid {
+		response.ParentID = &chirp.ParentID.UUID
+	}
+	if chirp.RechirpOf.Valid {
+		response.RechirpOf = &chirp.RechirpOf.UUID
+	}
+	return response
+}
+
+// toChirps converts database rows to their JSON form, filling in the counts
+// that live in other rows with one query per list rather than per chirp.
+// liked_by_me is only filled in when there is a viewer.
+func (cfg *apiConfig) toChirps(ctx context.Context, chirps []database.Chirp, viewerID uuid.NullUUID) ([]Chirp, error) {
+	return cfg.buildChirps(ctx, chirps, viewerID, true)
+}
+
+// buildChirps does the work for toChirps. Rechirped originals are embedded
+// one level deep only, so a chain of quotes costs a fixed number of queries.
+func (cfg *apiConfig) buildChirps(ctx context.Context, chirps []database.Chirp, viewerID uuid.NullUUID, embedRechirps bool) ([]Chirp, error) {
+	response := []Chirp{}
+	ids := []uuid.UUID{}
+	userIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		response = append(response, toChirp(chirp))
+		ids = append(ids, chirp.ID)
+		userIDs = append(userIDs, chirp.UserID)
+	}
+	authors, err := cfg.getAuthors(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	replyCounts, err := cfg.db.GetReplyCounts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[uuid.UUID]int64{}
+	for _, count := range replyCounts {
+		counts[count.ParentID.UUID] = count.ReplyCount
+	}
+	likeCounts, err := cfg.db.GetLikeCounts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	likes := map[uuid.UUID]int64{}
+	for _, count := range likeCounts {
+		likes[count.ChirpID] = count.LikeCount
+	}
+	rechirpCounts, err := cfg.db.GetRechirpCounts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	rechirps := map[uuid.UUID]int64{}
+	for _, count := range rechirpCounts {
+		rechirps[count.RechirpOf.UUID] = count.RechirpCount
+	}
+	entities, err := cfg.getChirpEntities(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	attached, err := cfg.getChirpMedia(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	liked := map[uuid.UUID]bool{}
+	if viewerID.Valid {
+		likedIDs, err := cfg.db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+			UserID:   viewerID.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+	}
+	originals := map[uuid.UUID]Chirp{}
+	if embedRechirps {
+		originalIDs := []uuid.UUID{}
+		for _, chirp := range chirps {
+			if chirp.RechirpOf.Valid {
+				originalIDs = append(originalIDs, chirp.RechirpOf.UUID)
+			}
+		}
+		if len(originalIDs) > 0 {
+			rows, err := cfg.db.GetChirpsByIDs(ctx, originalIDs)
+			if err != nil {
+				return nil, err
+			}
+			embedded, err := cfg.buildChirps(ctx, rows, viewerID, false)
+			if err != nil {
+				return nil, err
+			}
+			for _, original := range embedded {
+				originals[original.ID] = original
+			}
+		}
+	}
+	for i := range response {
+		if author, ok := authors[response[i].UserID]; ok {
+			response[i].Author = &author
+		}
+		response[i].ReplyCount = counts[response[i].ID]
+		response[i].LikeCount = likes[response[i].ID]
+		response[i].RechirpCount = rechirps[response[i].ID]
+		if chirpEntities, ok := entities[response[i].ID]; ok {
+			response[i].Entities = chirpEntities
+		}
+		if chirpMedia, ok := attached[response[i].ID]; ok {
+			response[i].Media = chirpMedia
+		}
+		if viewerID.Valid {
+			likedByMe := liked[response[i].ID]
+			response[i].LikedByMe = &likedByMe
+		}
+		if response[i].RechirpOf != nil {
+			if original, ok := originals[*response[i].RechirpOf]; ok {
+				response[i].Rechirp = &original
+			}
+		}
+	}
+	return response, nil
 }
 
 func (cfg *apiConfig) handlerAddChirp(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	req := struct {
-		Body string `json:"body"`
+		Body     string      `json:"body"`
+		ReplyTo  *uuid.UUID  `json:"reply_to"`
+		MediaIDs []uuid.UUID `json:"media_ids"`
 	}{}
 	if err := decoder.Decode(&req); err != nil {
 		respondError(w, http.StatusInternalServerError, "Error decoding request", err)
 		return
 	}
-	token, err := auth.GetBearerToken(r.Header)
+	userID, _ := auth.UserIDFromContext(r.Context())
+	filter, err := cfg.loadProfanityFilter(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error loading word filter", err)
+		return
+	}
+	checked, err := validateChirpBody(req.Body, filter)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Could not find valid JWT", err)
+		respondError(w, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	body := checked.Body
+	parentID := uuid.NullUUID{}
+	if req.ReplyTo != nil {
+		parent, err := cfg.db.GetChirpByID(r.Context(), *req.ReplyTo)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Chirp being replied to does not exist", err)
+			return
+		}
+		parentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid JWT", err)
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
 		return
 	}
-	if len(req.Body) > 140 {
-		respondError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if !checkChirpMedia(w, r, qtx, userID, req.MediaIDs) {
+		return
 	}
-	newChirp, err := cfg.db.AddChirp(r.Context(), database.AddChirpParams{
-		Body:   removeBadWords(req.Body),
-		UserID: userID,
+	newChirp, err := qtx.AddChirp(r.Context(), database.AddChirpParams{
+		Body:     body,
+		UserID:   userID,
+		ParentID: parentID,
 	})
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Error adding Chirp to database", err)
 		return
 	}
-	chirp := Chirp{
-		ID:        newChirp.ID,
-		CreatedAt: newChirp.CreatedAt,
-		UpdatedAt: newChirp.UpdatedAt,
-		Body:      newChirp.Body,
-		UserID:    newChirp.UserID,
+	if err = saveChirpEntities(r.Context(), qtx, newChirp); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving hashtags and mentions", err)
+		return
+	}
+	for i, mediaID := range req.MediaIDs {
+		if err = qtx.AddChirpMedia(r.Context(), database.AddChirpMediaParams{
+			ChirpID:  newChirp.ID,
+			MediaID:  mediaID,
+			Position: int32(i),
+		}); err != nil {
+			respondError(w, http.StatusInternalServerError, "Error attaching media", err)
+			return
+		}
 	}
-	respondWithJSON(w, http.StatusCreated, chirp)
+	if err = flagChirp(r.Context(), qtx, newChirp.ID, checked.Flagged); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error flagging Chirp for review", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error adding Chirp to database", err)
+		return
+	}
+	response, err := cfg.toChirps(r.Context(), []database.Chirp{newChirp}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving Chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, response[0])
 }
 
 func (cfg *apiConfig) handlerGetAllChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID := viewerFromContext(r.Context())
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
 	var chirps []database.Chirp
 	if userID := r.URL.Query().Get("author_id"); userID != "" {
 		id, err := uuid.Parse(userID)
 		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Error getting ID", err)
+			respondError(w, http.StatusBadRequest, "Invalid author_id", err)
 			return
 		}
-		chirpsList, err := cfg.db.GetChirpsByUser(r.Context(), id)
+		chirps, err = cfg.getChirpsByUser(r.Context(), id, page)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "Error retrieving Chirps", err)
 			return
 		}
-		chirps = append(chirps, chirpsList...)
-
 	} else {
-		chirpsList, err := cfg.db.GetAllChirps(r.Context())
+		chirps, err = cfg.getAllChirps(r.Context(), page)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "Error retrieving Chirps", err)
 			return
 		}
-		chirps = append(chirps, chirpsList...)
 	}
-	response := []Chirp{}
-	for _, chirp := range chirps {
-		response = append(response, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+	chirps = page.trim(w, r, chirps)
+	response, err := cfg.toChirps(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving Chirps", err)
+		return
 	}
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+func (cfg *apiConfig) getAllChirps(ctx context.Context, page pageRequest) ([]database.Chirp, error) {
+	if page.descending {
+		return cfg.db.GetAllChirpsDesc(ctx, database.GetAllChirpsDescParams{
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	return cfg.db.GetAllChirps(ctx, database.GetAllChirpsParams{
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+}
+
+func (cfg *apiConfig) getChirpsByUser(ctx context.Context, userID uuid.UUID, page pageRequest) ([]database.Chirp, error) {
+	if page.descending {
+		return cfg.db.GetChirpsByUserDesc(ctx, database.GetChirpsByUserDescParams{
+			UserID:          userID,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	return cfg.db.GetChirpsByUser(ctx, database.GetChirpsByUserParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+}
+
 func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request) {
+	viewerID := viewerFromContext(r.Context())
 	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "Error parsing ID", err)
@@ -105,26 +323,16 @@ func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request
 		respondError(w, http.StatusNotFound, "Unable to find Chirp", err)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, Chirp{
-		ID:        userChirp.ID,
-		CreatedAt: userChirp.CreatedAt,
-		UpdatedAt: userChirp.UpdatedAt,
-		Body:      userChirp.Body,
-		UserID:    userChirp.UserID,
-	})
-}
-
-func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+	response, err := cfg.toChirps(r.Context(), []database.Chirp{userChirp}, viewerID)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	userID, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		w.WriteHeader(http.StatusForbidden)
+		respondError(w, http.StatusInternalServerError, "Error retrieving Chirp", err)
 		return
 	}
+	respondWithJSON(w, http.StatusOK, response[0])
+}
+
+func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
 	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -145,3 +353,90 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Body string `json:"body"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	filter, err := cfg.loadProfanityFilter(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error loading word filter", err)
+		return
+	}
+	checked, err := validateChirpBody(req.Body, filter)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	body := checked.Body
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	chirp, err := qtx.GetChirpByIDForUpdate(r.Context(), chirpID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find Chirp", err)
+		return
+	}
+	if chirp.UserID != userID {
+		respondError(w, http.StatusForbidden, "You can only edit your own Chirps", nil)
+		return
+	}
+	// A plain rechirp has nothing of its own to edit.
+	if chirp.Body == "" && chirp.RechirpOf.Valid {
+		respondError(w, http.StatusBadRequest, "Plain rechirps can't be edited", nil)
+		return
+	}
+	if err = qtx.AddChirpRevision(r.Context(), database.AddChirpRevisionParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving Chirp revision", err)
+		return
+	}
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: body,
+	})
+	if uniqueViolation(err) == plainRechirpConstraint {
+		respondError(w, http.StatusConflict, "You have already rechirped this Chirp", err)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating Chirp", err)
+		return
+	}
+	if err = saveChirpEntities(r.Context(), qtx, updated); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving hashtags and mentions", err)
+		return
+	}
+	if err = flagChirp(r.Context(), qtx, updated.ID, checked.Flagged); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error flagging Chirp for review", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating Chirp", err)
+		return
+	}
+	response, err := cfg.toChirps(r.Context(), []database.Chirp{updated}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving Chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response[0])
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/loginlimit"
+	"github.com/snowkittyselene/chirpy/internal/mail"
+)
+
+const emailVerificationLifetime = 24 * time.Hour
+
+// Resending the verification email is limited per user, since the address
+// it goes to can be anyone's, and per IP address, so one client can't get
+// around that with many accounts. Like password reset requests, every
+// request counts and none lock out.
+var (
+	userVerificationEmailPolicy = loginlimit.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
+	ipVerificationEmailPolicy = loginlimit.Policy{
+		FreeAttempts: 10,
+		BaseDelay:    time.Minute,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+func verificationEmailThrottles(userID uuid.UUID, ip string) []loginThrottle {
+	return []loginThrottle{
+		{kind: "verify_user", subject: userID.String(), policy: userVerificationEmailPolicy},
+		{kind: "verify_ip", subject: ip, policy: ipVerificationEmailPolicy},
+	}
+}
+
+// loadMailer picks how mail is sent: through SMTP_ADDR when it is set,
+// otherwise into .eml files in MAIL_DIR, otherwise to the log.
+func loadMailer() mail.Sender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "chirpy@localhost"
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mail.NewSMTPSender(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return mail.NewFileSender(dir, from)
+	}
+	log.Print("Neither SMTP_ADDR nor MAIL_DIR is set, logging mail instead of sending it")
+	return mail.LogSender{}
+}
+
+// sendVerificationEmail mails the user a fresh verification token for
+// email, replacing any token sent before.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	if err = cfg.db.DeleteEmailVerificationTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationLifetime),
+	}); err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Welcome to Chirpy!\n\nTo confirm this is your email address, verify it with this code:\n\n%s\n\nThe code expires in %s. If you didn't sign up for Chirpy, you can ignore this email.\n",
+			token, emailVerificationLifetime),
+	})
+}
+
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Token string `json:"token"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	stored, err := cfg.db.ConsumeEmailVerificationToken(r.Context(), auth.HashToken(req.Token))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Verification code is invalid or has expired", err)
+		return
+	}
+	// The user may have changed their email since the code was sent.
+	u, err := cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    stored.UserID,
+		Email: stored.Email,
+	})
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Verification code is for an old email address", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, User{
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		IsChirpyRed:   u.IsChirpyRed,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+		Handle:        u.Handle,
+		DisplayName:   u.DisplayName,
+		Bio:           u.Bio,
+		AvatarURL:     u.AvatarUrl,
+		AvatarMediaID: uuidOrNil(u.AvatarMediaID),
+	})
+}
+
+func (cfg *apiConfig) handlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find user", err)
+		return
+	}
+	if user.EmailVerified {
+		respondError(w, http.StatusConflict, "Email is already verified", nil)
+		return
+	}
+	wait, err := cfg.countAttempt(r.Context(), verificationEmailThrottles(user.ID, clientIP(r)), clientIP(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking verification requests", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyRequests(w, wait, "Too many verification emails, try again later")
+		return
+	}
+	if err = cfg.sendVerificationEmail(r.Context(), user.ID, user.Email); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error sending verification email", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// middlewareVerifiedEmail only lets users with a verified email through. It
+// must sit behind the auth middleware.
+func (cfg *apiConfig) middlewareVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := auth.UserIDFromContext(r.Context())
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondError(w, http.StatusUnauthorized, "Unable to find user", err)
+			return
+		}
+		if !user.EmailVerified {
+			respondError(w, http.StatusForbidden, "Verify your email address first", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/snowkittyselene/chirpy/internal/auth"
+)
+
+func TestResendVerificationThrottled(t *testing.T) {
+	cfg := testConfig(t)
+	credentials := map[string]string{"email": testEmail, "password": testPassword}
+	if rec := serveJSON(t, http.HandlerFunc(cfg.handlerAddUser), http.MethodPost, "", credentials, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("signing up: %d %s", rec.Code, rec.Body)
+	}
+	var user User
+	if rec := serveJSON(t, http.HandlerFunc(cfg.handlerLogin), http.MethodPost, "", credentials, &user); rec.Code != http.StatusOK {
+		t.Fatalf("logging in: %d %s", rec.Code, rec.Body)
+	}
+	resend := auth.NewMiddleware(cfg.keys, cfg.revocations).Required(http.HandlerFunc(cfg.handlerResendVerification))
+	for i := 0; i <= userVerificationEmailPolicy.FreeAttempts; i++ {
+		if rec := serveJSON(t, resend, http.MethodPost, user.Token, nil, nil); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d: expected 202, got %d", i+1, rec.Code)
+		}
+	}
+	rec := serveJSON(t, resend, http.MethodPost, user.Token, nil, nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+type Follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, _ := auth.UserIDFromContext(r.Context())
+	followedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	if followedID == followerID {
+		respondError(w, http.StatusBadRequest, "You cannot follow yourself", nil)
+		return
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), followedID); err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find user", err)
+		return
+	}
+	if err := cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FollowedID: followedID,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error following user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, _ := auth.UserIDFromContext(r.Context())
+	followedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	if err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FollowedID: followedID,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error unfollowing user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	rows, err := cfg.db.GetFollowers(r.Context(), database.GetFollowersParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving followers", err)
+		return
+	}
+	follows := []Follow{}
+	for _, row := range rows {
+		follows = append(follows, Follow{UserID: row.FollowerID, CreatedAt: row.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, trimFollows(w, r, page, follows))
+}
+
+func (cfg *apiConfig) handlerGetFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	rows, err := cfg.db.GetFollowing(r.Context(), database.GetFollowingParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageLimit:       page.queryLimit(),
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving followed users", err)
+		return
+	}
+	follows := []Follow{}
+	for _, row := range rows {
+		follows = append(follows, Follow{UserID: row.FollowedID, CreatedAt: row.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, trimFollows(w, r, page, follows))
+}
+
+// trimFollows is page.trim for follow listings, which are keyed on
+// (created_at, user_id) instead of a chirp.
+func trimFollows(w http.ResponseWriter, r *http.Request, page pageRequest, follows []Follow) []Follow {
+	if len(follows) <= int(page.limit) {
+		return follows
+	}
+	follows = follows[:page.limit]
+	last := follows[len(follows)-1]
+	setNextPageLink(w, r, encodeCursor(last.CreatedAt, last.UserID))
+	return follows
+}
+
+// handlerGetFeed returns chirps from everyone the caller follows. Unlike the
+// other listings it defaults to newest first.
+func (cfg *apiConfig) handlerGetFeed(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if r.URL.Query().Get("sort") == "" {
+		page.descending = true
+	}
+	var chirps []database.Chirp
+	if page.descending {
+		chirps, err = cfg.db.GetFeedDesc(r.Context(), database.GetFeedDescParams{
+			FollowerID:      userID,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.GetFeed(r.Context(), database.GetFeedParams{
+			FollowerID:      userID,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving feed", err)
+		return
+	}
+	chirps = page.trim(w, r, chirps)
+	response, err := cfg.toChirps(r.Context(), chirps, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving feed", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
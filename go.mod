@@ -10,4 +10,10 @@ require (
 
 require golang.org/x/crypto v0.33.0
 
-require github.com/golang-jwt/jwt/v5 v5.2.1
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	golang.org/x/image v0.24.0
+	golang.org/x/text v0.22.0
+)
+
+require golang.org/x/sys v0.30.0 // indirect
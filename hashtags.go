@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/entities"
+)
+
+const defaultTrendingWindow = 24 * time.Hour
+const maxTrendingWindow = 7 * 24 * time.Hour
+const defaultTrendingLimit = 10
+
+// Entities holds the hashtags and mentions in a chirp body. Offsets are in
+// Unicode code points and the end offset is exclusive.
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+}
+
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int32  `json:"start"`
+	End   int32  `json:"end"`
+}
+
+type Mention struct {
+	Mention string     `json:"mention"`
+	UserID  *uuid.UUID `json:"user_id"`
+	Start   int32      `json:"start"`
+	End     int32      `json:"end"`
+}
+
+type TrendingHashtag struct {
+	Tag        string `json:"tag"`
+	ChirpCount int64  `json:"chirp_count"`
+}
+
+// saveChirpEntities replaces the stored hashtags and mentions of a chirp with
+// the ones in its current body. Mentions of a handle are linked to the user
+// it belongs to, when there is one. Mentions of an email address never are:
+// that would let anyone find out whose account an address is.
+func saveChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := q.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+	for _, tag := range entities.Hashtags(chirp.Body) {
+		if err := q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:     chirp.ID,
+			Tag:         tag.Text,
+			StartOffset: int32(tag.Start),
+			EndOffset:   int32(tag.End),
+		}); err != nil {
+			return err
+		}
+	}
+	mentions := entities.Mentions(chirp.Body)
+	handles := []string{}
+	for _, mention := range mentions {
+		if !entities.IsEmail(mention.Text) {
+			handles = append(handles, mention.Text)
+		}
+	}
+	users := map[string]uuid.UUID{}
+	if len(handles) > 0 {
+		rows, err := q.GetUsersByHandles(ctx, handles)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			users[row.Handle] = row.ID
+		}
+	}
+	for _, mention := range mentions {
+		userID := uuid.NullUUID{}
+		if id, ok := users[mention.Text]; ok {
+			userID = uuid.NullUUID{UUID: id, Valid: true}
+		}
+		if err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID:     chirp.ID,
+			Mention:     mention.Text,
+			UserID:      userID,
+			StartOffset: int32(mention.Start),
+			EndOffset:   int32(mention.End),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) getChirpEntities(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID]Entities, error) {
+	hashtags, err := cfg.db.GetHashtagsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := cfg.db.GetMentionsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	found := map[uuid.UUID]Entities{}
+	get := func(id uuid.UUID) Entities {
+		if e, ok := found[id]; ok {
+			return e
+		}
+		return Entities{Hashtags: []Hashtag{}, Mentions: []Mention{}}
+	}
+	for _, tag := range hashtags {
+		e := get(tag.ChirpID)
+		e.Hashtags = append(e.Hashtags, Hashtag{
+			Tag:   tag.Tag,
+			Start: tag.StartOffset,
+			End:   tag.EndOffset,
+		})
+		found[tag.ChirpID] = e
+	}
+	for _, mention := range mentions {
+		e := get(mention.ChirpID)
+		m := Mention{
+			Mention: mention.Mention,
+			Start:   mention.StartOffset,
+			End:     mention.EndOffset,
+		}
+		if mention.UserID.Valid {
+			m.UserID = &mention.UserID.UUID
+		}
+		e.Mentions = append(e.Mentions, m)
+		found[mention.ChirpID] = e
+	}
+	return found, nil
+}
+
+func (cfg *apiConfig) handlerGetChirpsByHashtag(w http.ResponseWriter, r *http.Request) {
+	viewerID := viewerFromContext(r.Context())
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	var chirps []database.Chirp
+	if page.descending {
+		chirps, err = cfg.db.GetChirpsByHashtagDesc(r.Context(), database.GetChirpsByHashtagDescParams{
+			Tag:             tag,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.GetChirpsByHashtag(r.Context(), database.GetChirpsByHashtagParams{
+			Tag:             tag,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving Chirps", err)
+		return
+	}
+	chirps = page.trim(w, r, chirps)
+	response, err := cfg.toChirps(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving Chirps", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerGetTrendingHashtags counts the chirps using each hashtag over the
+// `window` before now, given as a Go duration such as "6h".
+func (cfg *apiConfig) handlerGetTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	window := defaultTrendingWindow
+	if windowStr := query.Get("window"); windowStr != "" {
+		parsed, err := time.ParseDuration(windowStr)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			respondError(w, http.StatusBadRequest, "window must be a duration between 0 and 168h", err)
+			return
+		}
+		window = parsed
+	}
+	limit := defaultTrendingLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			respondError(w, http.StatusBadRequest, "limit must be a positive integer", err)
+			return
+		}
+		limit = min(parsed, maxPageSize)
+	}
+	rows, err := cfg.db.GetTrendingHashtags(r.Context(), database.GetTrendingHashtagsParams{
+		Since:    time.Now().UTC().Add(-window),
+		TagLimit: int32(limit),
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving trending hashtags", err)
+		return
+	}
+	response := []TrendingHashtag{}
+	for _, row := range rows {
+		response = append(response, TrendingHashtag{Tag: row.Tag, ChirpCount: row.ChirpCount})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -1,56 +1,113 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	pass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(pass), err
+var (
+	// ErrTokenRevoked is returned for a validly signed token that is on
+	// the revocation list.
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrRevocationCheck wraps a failure to consult the revocation list.
+	ErrRevocationCheck = errors.New("could not check whether token is revoked")
+)
+
+// Claims are the contents of a Chirpy access token.
+type Claims struct {
+	Role string `json:"role,omitempty"`
+	// IssuedAt stands in for the registered iat claim, keeping
+	// milliseconds. Revoking every token a user holds rejects the ones
+	// issued before the revocation, and whole seconds are too coarse for
+	// that. Setting jwt.TimePrecision instead would change it for every
+	// user of the jwt package in the process.
+	IssuedAt *millisecondDate `json:"iat,omitempty"`
+	jwt.RegisteredClaims
 }
 
-func CheckPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+func (c Claims) GetIssuedAt() (*jwt.NumericDate, error) {
+	if c.IssuedAt == nil {
+		return nil, nil
+	}
+	return &jwt.NumericDate{Time: c.IssuedAt.Time}, nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	startTime := jwt.NewNumericDate(time.Now().UTC())
-	endTime := jwt.NewNumericDate(time.Now().UTC().Add(expiresIn))
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  startTime,
-		ExpiresAt: endTime,
-		Subject:   userID.String(),
-	})
-	return token.SignedString([]byte(tokenSecret))
+// millisecondDate is a JWT NumericDate kept to the millisecond.
+type millisecondDate struct {
+	time.Time
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	type claims struct{ jwt.RegisteredClaims }
-	token, err := jwt.ParseWithClaims(tokenString, &claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+func (d millisecondDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(float64(d.UnixMilli())/1000, 'f', 3, 64)), nil
+}
+
+func (d *millisecondDate) UnmarshalJSON(b []byte) error {
+	seconds, err := strconv.ParseFloat(string(b), 64)
 	if err != nil {
-		return uuid.Nil, err
+		return fmt.Errorf("date must be a number of seconds: %w", err)
 	}
-	idStr, err := token.Claims.GetSubject()
+	d.Time = time.UnixMilli(int64(math.Round(seconds * 1000))).UTC()
+	return nil
+}
+
+func MakeJWT(userID uuid.UUID, role string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	now := time.Now().UTC()
+	return keys.sign(Claims{
+		Role:     role,
+		IssuedAt: &millisecondDate{now.Truncate(time.Millisecond)},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+	})
+}
+
+// ValidateJWT checks a token's signature and expiry, and that it isn't on
+// the revocation list, returning the ID of the user it was issued to.
+// revocations may be nil, in which case every validly signed token is
+// accepted until it expires.
+func ValidateJWT(ctx context.Context, tokenString string, keys *KeySet, revocations RevocationList) (uuid.UUID, error) {
+	claims, err := ParseJWT(ctx, tokenString, keys, revocations)
 	if err != nil {
 		return uuid.Nil, err
 	}
-	id, err := uuid.Parse(idStr)
+	return uuid.Parse(claims.Subject)
+}
+
+// ParseJWT validates a token like ValidateJWT but returns all of its claims.
+func ParseJWT(ctx context.Context, tokenString string, keys *KeySet, revocations RevocationList) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keys.keyfunc)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
-	return id, nil
+	if _, err := uuid.Parse(claims.Subject); err != nil {
+		return nil, err
+	}
+	if revocations != nil {
+		revoked, err := revocations.IsRevoked(ctx, claims)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRevocationCheck, err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -58,7 +115,11 @@ func GetBearerToken(headers http.Header) (string, error) {
 	if bearer == "" {
 		return "", fmt.Errorf("bearer should not be empty")
 	}
-	return strings.Fields(strings.TrimSpace(bearer))[1], nil
+	fields := strings.Fields(bearer)
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "Bearer") {
+		return "", fmt.Errorf("authorization header should be in the form 'Bearer <token>'")
+	}
+	return fields[1], nil
 }
 
 func MakeRefreshToken() (string, error) {
@@ -69,6 +130,13 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(token), nil
 }
 
+// HashToken hashes a single-use token, such as one sent by email, for
+// storage. The tokens are random enough that a fast unsalted hash is fine.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	apiKey := headers.Get("Authorization")
 	if apiKey == "" {
@@ -1,43 +1,48 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 func TestCheckHashEqual(t *testing.T) {
 	password := "hunter2"
-	hash, err := HashPassword(password)
+	h := NewPasswordHasher()
+	hash, err := h.Hash(password)
 	if err != nil {
 		t.Fatalf("Error hashing password: %v", err)
 	}
-	if err = CheckPasswordHash(password, hash); err != nil {
+	if _, err = h.Check(password, hash); err != nil {
 		t.Fatalf("Password is not correct, should be")
 	}
 }
 
 func TestCheckHashNotEqual(t *testing.T) {
 	password := "hunter2"
-	hash, err := HashPassword(password)
+	h := NewPasswordHasher()
+	hash, err := h.Hash(password)
 	if err != nil {
 		t.Fatalf("Error hashing password: %v", err)
 	}
-	if err = CheckPasswordHash("password", hash); err == nil {
+	if _, err = h.Check("password", hash); err == nil {
 		t.Fatalf("Password is correct, shouldn't be")
 	}
 }
 
 func TestCheckJWTValid(t *testing.T) {
 	id := uuid.New()
-	token, err := MakeJWT(id, "secret", 5*time.Second)
+	token, err := MakeJWT(id, RoleUser, testKeys, 5*time.Second)
 	if err != nil {
 		t.Fatalf("Error generating token: %v", err)
 	}
-	returnedID, err := ValidateJWT(token, "secret")
+	returnedID, err := ValidateJWT(context.Background(), token, testKeys, nil)
 	if err != nil {
 		t.Fatalf("Error validating token: %v", err)
 	}
@@ -48,12 +53,12 @@ func TestCheckJWTValid(t *testing.T) {
 
 func TestCheckJWTInvalidExpired(t *testing.T) {
 	id := uuid.New()
-	token, err := MakeJWT(id, "secret", 5*time.Millisecond)
+	token, err := MakeJWT(id, RoleUser, testKeys, 5*time.Millisecond)
 	if err != nil {
 		t.Fatalf("Error generating token; %v", err)
 	}
 	time.Sleep(10 * time.Millisecond)
-	_, err = ValidateJWT(token, "secret")
+	_, err = ValidateJWT(context.Background(), token, testKeys, nil)
 	if err == nil {
 		t.Fatalf("Expected expired token")
 	} else if !strings.Contains(err.Error(), "token is expired") {
@@ -63,11 +68,11 @@ func TestCheckJWTInvalidExpired(t *testing.T) {
 
 func TestCheckJWTInvalidWrongSecret(t *testing.T) {
 	id := uuid.New()
-	token, err := MakeJWT(id, "secret", 5*time.Second)
+	token, err := MakeJWT(id, RoleUser, testKeys, 5*time.Second)
 	if err != nil {
 		t.Fatalf("Error generating token: %v", err)
 	}
-	_, err = ValidateJWT(token, "terces")
+	_, err = ValidateJWT(context.Background(), token, otherKeys, nil)
 	if err == nil {
 		t.Fatalf("Expected expired token")
 	} else if !strings.Contains(err.Error(), "signature is invalid") {
@@ -75,6 +80,39 @@ func TestCheckJWTInvalidWrongSecret(t *testing.T) {
 	}
 }
 
+func TestCheckJWTRevoked(t *testing.T) {
+	token, err := MakeJWT(uuid.New(), RoleUser, testKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	claims, err := ParseJWT(context.Background(), token, testKeys, nil)
+	if err != nil {
+		t.Fatalf("Error validating token: %v", err)
+	}
+	revocations := revokedIDs{claims.TokenID(): true}
+	if _, err = ValidateJWT(context.Background(), token, testKeys, revocations); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestJWTIssuedAtMilliseconds(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	token, err := MakeJWT(uuid.New(), RoleUser, testKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	claims, err := ParseJWT(context.Background(), token, testKeys, nil)
+	if err != nil {
+		t.Fatalf("Error validating token: %v", err)
+	}
+	if claims.IssuedAt == nil || claims.IssuedAt.Before(before) || claims.IssuedAt.After(time.Now()) {
+		t.Fatalf("expected an issue time to the millisecond after %v, got %v", before, claims.IssuedAt)
+	}
+	if jwt.TimePrecision != time.Second {
+		t.Fatalf("expected jwt.TimePrecision to be left alone, got %v", jwt.TimePrecision)
+	}
+}
+
 func TestCheckBearerValid(t *testing.T) {
 	header := http.Header{}
 	header.Add("Authorization", "Bearer tokentest")
@@ -94,3 +132,63 @@ func TestCheckBearerNoBearer(t *testing.T) {
 		t.Fatalf("expected no return, got %v", token)
 	}
 }
+
+func TestCheckJWTRole(t *testing.T) {
+	id := uuid.New()
+	token, err := MakeJWT(id, RoleAdmin, testKeys, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	claims, err := ParseJWT(context.Background(), token, testKeys, nil)
+	if err != nil {
+		t.Fatalf("Error validating token: %v", err)
+	}
+	if claims.Role != RoleAdmin {
+		t.Fatalf("expected role %s, got %s", RoleAdmin, claims.Role)
+	}
+}
+
+func TestMakeJWTUniqueID(t *testing.T) {
+	id := uuid.New()
+	seen := map[uuid.UUID]bool{}
+	for range 3 {
+		token, err := MakeJWT(id, RoleUser, testKeys, 5*time.Second)
+		if err != nil {
+			t.Fatalf("Error generating token: %v", err)
+		}
+		claims, err := ParseJWT(context.Background(), token, testKeys, nil)
+		if err != nil {
+			t.Fatalf("Error validating token: %v", err)
+		}
+		jti := claims.TokenID()
+		if jti == uuid.Nil || seen[jti] {
+			t.Fatalf("expected a fresh jti, got %v", jti)
+		}
+		seen[jti] = true
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	if HashToken(token) != HashToken(token) {
+		t.Fatalf("expected hashing to be deterministic")
+	}
+	if HashToken(token) == token || HashToken(token) == HashToken(token+"x") {
+		t.Fatalf("expected distinct hashes")
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	if !HasRole(RoleAdmin, RoleModerator) {
+		t.Fatalf("expected admin to have moderator permissions")
+	}
+	if HasRole(RoleUser, RoleModerator) {
+		t.Fatalf("expected user not to have moderator permissions")
+	}
+	if HasRole("", RoleUser) {
+		t.Fatalf("expected an empty role to have no permissions")
+	}
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported key algorithms for GenerateKey.
+const (
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeRSA     = "rsa"
+)
+
+const minRSABits = 2048
+
+// Key is one entry of a KeySet. Private is nil for keys that are only kept
+// around to verify tokens signed before a rotation.
+type Key struct {
+	ID      string
+	Public  crypto.PublicKey
+	Private crypto.Signer
+}
+
+// KeySet signs access tokens with one key and verifies them with any of its
+// keys, picked by the kid header of the token.
+//
+// Rotating keys without logging anyone out goes:
+//  1. Generate a new key into the keys directory and restart every
+//     instance. The new key is now trusted and published in the JWKS, but
+//     tokens are still signed with the old one.
+//  2. Once every instance (and every JWKS consumer's cache) has the new
+//     key, point JWT_SIGNING_KEY_ID at it and restart again.
+//  3. After the old key's last tokens have expired, delete it, or replace
+//     it with its public half if other services should keep trusting it.
+type KeySet struct {
+	signing string
+	keys    map[string]setKey
+}
+
+type setKey struct {
+	Key
+	method jwt.SigningMethod
+	secret []byte
+}
+
+// NewKeySet builds a KeySet from keys. signingKID picks the signing key;
+// when empty, the last private key in kid order is used.
+func NewKeySet(signingKID string, keys ...Key) (*KeySet, error) {
+	set := &KeySet{keys: map[string]setKey{}}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("keys need an ID")
+		}
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		if key.Public == nil && key.Private != nil {
+			key.Public = key.Private.Public()
+		}
+		method, err := signingMethod(key.Public)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		set.keys[key.ID] = setKey{Key: key, method: method}
+	}
+	if signingKID == "" {
+		for _, kid := range set.keyIDs() {
+			if set.keys[kid].Private != nil {
+				signingKID = kid
+			}
+		}
+	}
+	key, ok := set.keys[signingKID]
+	if !ok || key.Private == nil {
+		return nil, fmt.Errorf("no private key with ID %q to sign with", signingKID)
+	}
+	set.signing = signingKID
+	return set, nil
+}
+
+// NewHMACKeySet signs and verifies with a shared secret (HS256). Nothing is
+// published in its JWKS, so only holders of the secret can verify tokens;
+// it is meant for local development.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{
+		keys: map[string]setKey{"": {method: jwt.SigningMethodHS256, secret: []byte(secret)}},
+	}
+}
+
+// LoadKeySet reads every .pem file in dir. A file's name without the
+// extension is its kid. Private keys (PKCS#8, or PKCS#1 for RSA) can sign
+// and verify; public keys (PKIX) only verify.
+func LoadKeySet(dir, signingKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key.ID = strings.TrimSuffix(filepath.Base(path), ".pem")
+		keys = append(keys, key)
+	}
+	return NewKeySet(signingKID, keys...)
+}
+
+// GenerateKey writes a new private key of the given type into dir and
+// returns its kid, which sorts after the kids of earlier generated keys.
+func GenerateKey(dir, keyType string) (string, error) {
+	var private crypto.Signer
+	var err error
+	switch keyType {
+	case KeyTypeEd25519:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case KeyTypeRSA:
+		private, err = rsa.GenerateKey(rand.Reader, minRSABits)
+	default:
+		return "", fmt.Errorf("unknown key type %q", keyType)
+	}
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	kid := time.Now().UTC().Format("20060102T150405Z")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	file, err := os.OpenFile(filepath.Join(dir, kid+".pem"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return "", err
+	}
+	return kid, file.Close()
+}
+
+func parseKey(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return Key{}, errors.New("unsupported private key")
+		}
+		return Key{Private: signer, Public: signer.Public()}, nil
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Private: private, Public: private.Public()}, nil
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Public: public}, nil
+	}
+	return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+func signingMethod(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch public := public.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA keys need at least %d bits", minRSABits)
+		}
+		return jwt.SigningMethodRS256, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", public)
+}
+
+func (k *KeySet) keyIDs() []string {
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	key := k.keys[k.signing]
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.secret != nil {
+		return token.SignedString(key.secret)
+	}
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// keyfunc finds the key a token was signed with. The token's alg has to be
+// the one that key is used with, so an RSA public key can never be mistaken
+// for an HMAC secret.
+func (k *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+	}
+	if key.secret != nil {
+		return key.secret, nil
+	}
+	return key.Public, nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public half of every verification key. Shared secrets are
+// never included.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, kid := range k.keyIDs() {
+		key := k.keys[kid]
+		jwk := JWK{KeyID: kid, Use: "sig", Algorithm: key.method.Alg()}
+		switch public := key.Public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
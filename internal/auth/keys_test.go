@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// testKeys and otherKeys use the same kid with different keys, so tokens
+// from one fail the other's signature check.
+var (
+	testKeys  = mustEd25519KeySet("test")
+	otherKeys = mustEd25519KeySet("test")
+)
+
+func mustEd25519KeySet(kid string) *KeySet {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	keys, err := NewKeySet(kid, Key{ID: kid, Private: private})
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+func TestKeySetRotation(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	before, err := NewKeySet("", Key{ID: "1", Private: oldKey})
+	if err != nil {
+		t.Fatalf("Error building key set: %v", err)
+	}
+	after, err := NewKeySet("", Key{ID: "1", Public: oldKey.Public()}, Key{ID: "2", Private: newKey})
+	if err != nil {
+		t.Fatalf("Error building key set: %v", err)
+	}
+	oldToken, err := MakeJWT(uuid.New(), RoleUser, before, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	if _, err = ValidateJWT(context.Background(), oldToken, after, nil); err != nil {
+		t.Fatalf("expected a token signed before rotation to verify, got %v", err)
+	}
+	newToken, err := MakeJWT(uuid.New(), RoleUser, after, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	if err != nil {
+		t.Fatalf("Error parsing token: %v", err)
+	}
+	if parsed.Header["kid"] != "2" || parsed.Method.Alg() != "RS256" {
+		t.Fatalf("expected RS256 with kid 2, got %v with kid %v", parsed.Method.Alg(), parsed.Header["kid"])
+	}
+	if _, err = ValidateJWT(context.Background(), newToken, before, nil); err == nil {
+		t.Fatalf("expected a token with an unknown kid to fail")
+	}
+}
+
+func TestKeySetRejectsHMACWithPublicKey(t *testing.T) {
+	// An attacker who knows the public key must not be able to use it as
+	// an HS256 secret.
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	keys, err := NewKeySet("rsa", Key{ID: "rsa", Private: rsaKey})
+	if err != nil {
+		t.Fatalf("Error building key set: %v", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()},
+	})
+	token.Header["kid"] = "rsa"
+	forged, err := token.SignedString(rsaKey.PublicKey.N.Bytes())
+	if err != nil {
+		t.Fatalf("Error signing token: %v", err)
+	}
+	if _, err = ValidateJWT(context.Background(), forged, keys, nil); err == nil {
+		t.Fatalf("expected an HS256 token to be rejected by an RSA key")
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	first, err := GenerateKey(dir, KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	// Key IDs have one-second resolution, so move the first key out of the
+	// way before generating another.
+	if err = os.Rename(filepath.Join(dir, first+".pem"), filepath.Join(dir, "19700101T000000Z.pem")); err != nil {
+		t.Fatalf("Error renaming key: %v", err)
+	}
+	second, err := GenerateKey(dir, KeyTypeRSA)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	keys, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("Error loading keys: %v", err)
+	}
+	if keys.signing != second {
+		t.Fatalf("expected to sign with %s, got %s", second, keys.signing)
+	}
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected 2 keys in the JWKS, got %d", len(jwks.Keys))
+	}
+	if jwks.Keys[0].KeyID != "19700101T000000Z" || jwks.Keys[0].KeyType != "OKP" || jwks.Keys[0].X == "" {
+		t.Fatalf("unexpected Ed25519 JWK: %+v", jwks.Keys[0])
+	}
+	if jwks.Keys[1].KeyType != "RSA" || jwks.Keys[1].E != "AQAB" {
+		t.Fatalf("unexpected RSA JWK: %+v", jwks.Keys[1])
+	}
+	if _, err = LoadKeySet(dir, "missing"); err == nil {
+		t.Fatalf("expected an unknown signing key ID to fail")
+	}
+}
+
+func TestHMACKeySetHasEmptyJWKS(t *testing.T) {
+	keys := NewHMACKeySet("secret")
+	token, err := MakeJWT(uuid.New(), RoleUser, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	if _, err = ValidateJWT(context.Background(), token, keys, nil); err != nil {
+		t.Fatalf("Error validating token: %v", err)
+	}
+	if len(keys.JWKS().Keys) != 0 {
+		t.Fatalf("expected the shared secret to stay out of the JWKS")
+	}
+}
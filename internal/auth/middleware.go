@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const claimsKey contextKey = iota
+
+// Middleware validates the bearer token of a request once and stores its
+// claims in the request context for handlers to read back with
+// UserIDFromContext or ClaimsFromContext. It validates with ParseJWT, so
+// tokens on the revocation list are rejected even if they have not expired
+// yet.
+type Middleware struct {
+	keys        *KeySet
+	revocations RevocationList
+}
+
+// NewMiddleware builds a Middleware. revocations may be nil, in which case
+// every validly signed token is accepted until it expires.
+func NewMiddleware(keys *KeySet, revocations RevocationList) *Middleware {
+	return &Middleware{keys: keys, revocations: revocations}
+}
+
+// Required rejects any request without a valid access token.
+func (m *Middleware) Required(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := GetBearerToken(r.Header)
+		if err != nil {
+			respondUnauthorized(w, "", "Could not find valid JWT", err)
+			return
+		}
+		m.serveWithToken(w, r, token, next)
+	})
+}
+
+// Optional lets anonymous requests through without claims, but still
+// rejects a request whose token is present and invalid.
+func (m *Middleware) Optional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, err := GetBearerToken(r.Header)
+		if err != nil {
+			respondUnauthorized(w, "invalid_request", "Could not find valid JWT", err)
+			return
+		}
+		m.serveWithToken(w, r, token, next)
+	})
+}
+
+func (m *Middleware) serveWithToken(w http.ResponseWriter, r *http.Request, token string, next http.Handler) {
+	claims, err := ParseJWT(r.Context(), token, m.keys, m.revocations)
+	switch {
+	case errors.Is(err, ErrTokenRevoked):
+		respondUnauthorized(w, "invalid_token", "JWT has been revoked", nil)
+		return
+	case errors.Is(err, ErrRevocationCheck):
+		log.Println(err)
+		respondJSONError(w, http.StatusInternalServerError, "Could not check JWT")
+		return
+	case err != nil:
+		respondUnauthorized(w, "invalid_token", "Invalid JWT", err)
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+}
+
+// RequireRole only lets requests through when their token carries at least
+// the given role. It must sit behind Required.
+func RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			respondUnauthorized(w, "", "Could not find valid JWT", nil)
+			return
+		}
+		if !HasRole(claims.Role, role) {
+			respondJSONError(w, http.StatusForbidden, "You do not have permission to do that")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+// UserIDFromContext returns the ID of the authenticated user, if any.
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return uuid.Nil, false
+	}
+	// ParseJWT has already checked the subject is a valid UUID.
+	return uuid.MustParse(claims.Subject), true
+}
+
+// respondUnauthorized answers with the same JSON error body as the rest of
+// the API. errorCode is the RFC 6750 error for the WWW-Authenticate header,
+// left out when the request had no credentials at all.
+func respondUnauthorized(w http.ResponseWriter, errorCode, msg string, err error) {
+	if err != nil {
+		log.Println(err)
+	}
+	challenge := `Bearer realm="chirpy"`
+	if errorCode != "" {
+		challenge += fmt.Sprintf(`, error="%s"`, errorCode)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondJSONError(w, http.StatusUnauthorized, msg)
+}
+
+func respondJSONError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: msg})
+}
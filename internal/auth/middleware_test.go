@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func serveWith(handler http.Handler, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareRequiredValid(t *testing.T) {
+	id := uuid.New()
+	token, err := MakeJWT(id, RoleUser, testKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	var gotID uuid.UUID
+	handler := NewMiddleware(testKeys, nil).Required(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID, _ = UserIDFromContext(r.Context())
+	}))
+	rec := serveWith(handler, "Bearer "+token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if gotID != id {
+		t.Fatalf("expected user ID %v in context, got %v", id, gotID)
+	}
+}
+
+func TestMiddlewareRequiredMissing(t *testing.T) {
+	handler := NewMiddleware(testKeys, nil).Required(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("handler should not be called")
+	}))
+	rec := serveWith(handler, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected a WWW-Authenticate header")
+	}
+}
+
+func TestMiddlewareRequiredInvalid(t *testing.T) {
+	handler := NewMiddleware(testKeys, nil).Required(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("handler should not be called")
+	}))
+	for _, header := range []string{"Bearer nonsense", "Bearer", "Basic abc"} {
+		rec := serveWith(handler, header)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for %q, got %d", header, rec.Code)
+		}
+	}
+}
+
+func TestMiddlewareOptionalAnonymous(t *testing.T) {
+	called := false
+	handler := NewMiddleware(testKeys, nil).Optional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if _, ok := UserIDFromContext(r.Context()); ok {
+			t.Fatalf("expected no user in context")
+		}
+	}))
+	rec := serveWith(handler, "")
+	if !called || rec.Code != http.StatusOK {
+		t.Fatalf("expected anonymous request to reach the handler")
+	}
+}
+
+func TestMiddlewareOptionalInvalid(t *testing.T) {
+	handler := NewMiddleware(testKeys, nil).Optional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("handler should not be called")
+	}))
+	rec := serveWith(handler, "Bearer nonsense")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	m := NewMiddleware(testKeys, nil)
+	handler := m.Required(RequireRole(RoleModerator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for role, want := range map[string]int{
+		RoleUser:      http.StatusForbidden,
+		RoleModerator: http.StatusOK,
+		RoleAdmin:     http.StatusOK,
+	} {
+		token, err := MakeJWT(uuid.New(), role, testKeys, time.Minute)
+		if err != nil {
+			t.Fatalf("Error generating token: %v", err)
+		}
+		if rec := serveWith(handler, "Bearer "+token); rec.Code != want {
+			t.Fatalf("expected %d for role %s, got %d", want, role, rec.Code)
+		}
+	}
+}
+
+type revokedIDs map[uuid.UUID]bool
+
+func (r revokedIDs) IsRevoked(_ context.Context, claims *Claims) (bool, error) {
+	return r[claims.TokenID()], nil
+}
+
+func TestMiddlewareRevokedToken(t *testing.T) {
+	revoked, err := MakeJWT(uuid.New(), RoleUser, testKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	claims, err := ParseJWT(context.Background(), revoked, testKeys, nil)
+	if err != nil {
+		t.Fatalf("Error validating token: %v", err)
+	}
+	valid, err := MakeJWT(uuid.New(), RoleUser, testKeys, time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	m := NewMiddleware(testKeys, revokedIDs{claims.TokenID(): true})
+	handler := m.Required(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if rec := serveWith(handler, "Bearer "+revoked); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a revoked token, got %d", rec.Code)
+	}
+	if rec := serveWith(handler, "Bearer "+valid); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a valid token, got %d", rec.Code)
+	}
+}
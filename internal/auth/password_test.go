@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	testArgon2 = &PasswordHasher{
+		Algorithm: Argon2id,
+		Argon2:    Argon2Params{Time: 1, Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32},
+	}
+	testBcrypt = &PasswordHasher{Algorithm: Bcrypt, BcryptCost: 4}
+)
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	for _, h := range []*PasswordHasher{testArgon2, testBcrypt} {
+		hash, err := h.Hash("hunter2")
+		if err != nil {
+			t.Fatalf("%s: error hashing password: %v", h.Algorithm, err)
+		}
+		needsRehash, err := h.Check("hunter2", hash)
+		if err != nil {
+			t.Fatalf("%s: password is not correct, should be: %v", h.Algorithm, err)
+		}
+		if needsRehash {
+			t.Errorf("%s: fresh hash needs rehash", h.Algorithm)
+		}
+		if _, err = h.Check("hunter3", hash); !errors.Is(err, ErrPasswordMismatch) {
+			t.Errorf("%s: wrong password gave %v, want ErrPasswordMismatch", h.Algorithm, err)
+		}
+	}
+}
+
+func TestArgon2HashFormat(t *testing.T) {
+	hash, err := testArgon2.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("Error hashing password: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %q isn't in PHC format", hash)
+	}
+	other, _ := testArgon2.Hash("hunter2")
+	if other == hash {
+		t.Errorf("two hashes of the same password are equal, salt isn't random")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	stronger := &PasswordHasher{
+		Algorithm: Argon2id,
+		Argon2:    Argon2Params{Time: 2, Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32},
+	}
+	costlier := &PasswordHasher{Algorithm: Bcrypt, BcryptCost: 5}
+	argonHash, _ := testArgon2.Hash("hunter2")
+	bcryptHash, _ := testBcrypt.Hash("hunter2")
+	tests := []struct {
+		name   string
+		hasher *PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"bcrypt to argon2id", testArgon2, bcryptHash, true},
+		{"argon2id to bcrypt", testBcrypt, argonHash, true},
+		{"argon2id parameters changed", stronger, argonHash, true},
+		{"bcrypt cost changed", costlier, bcryptHash, true},
+		{"longer salt only", &PasswordHasher{Algorithm: Argon2id, Argon2: Argon2Params{Time: 1, Memory: 64, Threads: 1, SaltLen: 32, KeyLen: 32}}, argonHash, false},
+	}
+	for _, tc := range tests {
+		got, err := tc.hasher.Check("hunter2", tc.hash)
+		if err != nil {
+			t.Errorf("%s: password is not correct, should be: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: needsRehash = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckMalformedHash(t *testing.T) {
+	for _, hash := range []string{"", "plaintext", "$argon2id$v=19$m=64,t=1$c2FsdA$a2V5", "$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5"} {
+		if _, err := testArgon2.Check("hunter2", hash); err == nil {
+			t.Errorf("hash %q was accepted", hash)
+		}
+	}
+}
+
+func TestPasswordHasherValidate(t *testing.T) {
+	for _, h := range []*PasswordHasher{testArgon2, testBcrypt, NewPasswordHasher()} {
+		if err := h.Validate(); err != nil {
+			t.Errorf("%s: %v", h.Algorithm, err)
+		}
+	}
+	for _, h := range []*PasswordHasher{
+		{Algorithm: "md5"},
+		{Algorithm: Bcrypt, BcryptCost: 99},
+		{Algorithm: Argon2id, Argon2: Argon2Params{Time: 0, Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32}},
+	} {
+		if err := h.Validate(); err == nil {
+			t.Errorf("%+v is valid, shouldn't be", h)
+		}
+	}
+}
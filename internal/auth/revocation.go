@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationList reports whether an access token has been revoked before
+// its expiry, either on its own (logout) or along with every other token
+// issued to its user up to some point (password change, admin sign-out).
+type RevocationList interface {
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+}
+
+// TokenID returns the jti of a token, or uuid.Nil for tokens issued
+// without one.
+func (c *Claims) TokenID() uuid.UUID {
+	id, err := uuid.Parse(c.ID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// IssuedBefore reports whether the token was issued at or before t. Tokens
+// without an issue time are treated as issued before anything.
+func (c *Claims) IssuedBefore(t time.Time) bool {
+	if c.IssuedAt == nil {
+		return true
+	}
+	return !c.IssuedAt.Time.After(t)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import "fmt"
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleLevels orders the roles so that each one can do everything the roles
+// below it can.
+var roleLevels = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ValidateRole(role string) error {
+	if _, ok := roleLevels[role]; !ok {
+		return fmt.Errorf("role must be one of user, moderator or admin")
+	}
+	return nil
+}
+
+// HasRole reports whether a user with role has at least the permissions of
+// required. Unknown roles have no permissions.
+func HasRole(role, required string) bool {
+	level, ok := roleLevels[role]
+	if !ok {
+		return false
+	}
+	return level >= roleLevels[required]
+}
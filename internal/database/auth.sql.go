@@ -7,43 +7,112 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-const updateUserCredentials = `-- name: UpdateUserCredentials :one
+const rehashUserPassword = `-- name: RehashUserPassword :exec
 UPDATE users
-SET email=$2,
-    hashed_password=$3,
+SET hashed_password=$1
+WHERE id=$2 AND hashed_password=$3
+`
+
+type RehashUserPasswordParams struct {
+	NewHash string
+	ID      uuid.UUID
+	OldHash string
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewHash, arg.ID, arg.OldHash)
+	return err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET email=COALESCE($1, email),
+    hashed_password=COALESCE($2, hashed_password),
+    handle=COALESCE($3, handle),
+    display_name=COALESCE($4, display_name),
+    bio=COALESCE($5, bio),
+    avatar_url=COALESCE($6, avatar_url),
+    avatar_media_id=CASE WHEN $6::text IS NULL THEN avatar_media_id ELSE $7::uuid END,
+    email_verified=(email_verified AND email=COALESCE($1, email)),
     updated_at=NOW()
-WHERE id=$1
-RETURNING id, created_at, updated_at, email, is_chirpy_red
+WHERE id=$8
+RETURNING id, created_at, updated_at, email, is_chirpy_red, role, email_verified, handle, display_name, bio, avatar_url, avatar_media_id
 `
 
-type UpdateUserCredentialsParams struct {
+type UpdateUserParams struct {
+	Email          sql.NullString
+	HashedPassword sql.NullString
+	Handle         sql.NullString
+	DisplayName    sql.NullString
+	Bio            sql.NullString
+	AvatarUrl      sql.NullString
+	AvatarMediaID  uuid.NullUUID
 	ID             uuid.UUID
-	Email          string
-	HashedPassword string
 }
 
-type UpdateUserCredentialsRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Email       string
-	IsChirpyRed bool
+type UpdateUserRow struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Email         string
+	IsChirpyRed   bool
+	Role          string
+	EmailVerified bool
+	Handle        string
+	DisplayName   string
+	Bio           string
+	AvatarUrl     string
+	AvatarMediaID uuid.NullUUID
 }
 
-func (q *Queries) UpdateUserCredentials(ctx context.Context, arg UpdateUserCredentialsParams) (UpdateUserCredentialsRow, error) {
-	row := q.db.QueryRowContext(ctx, updateUserCredentials, arg.ID, arg.Email, arg.HashedPassword)
-	var i UpdateUserCredentialsRow
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateUserRow, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.AvatarMediaID,
+		arg.ID,
+	)
+	var i UpdateUserRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.EmailVerified,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.AvatarMediaID,
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password=$2,
+    updated_at=NOW()
+WHERE id=$1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Entity is a hashtag or mention found in a chirp body. Text is normalised
+// to lower case and excludes the leading # or @. Start and End are offsets in
+// Unicode code points, End being exclusive, and cover the leading symbol.
+type Entity struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// A tag or mention has to start the body or follow something that is not
+// part of a word, so "a#b" and "me@example.com" are left alone.
+var hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])(#[\p{L}\p{N}_]+)`)
+var mentionRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])(@(?:[A-Za-z0-9_.+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+|[A-Za-z0-9_]+))`)
+
+func Hashtags(body string) []Entity {
+	return find(hashtagRegex, body)
+}
+
+// Mentions finds @handle and @email tokens.
+func Mentions(body string) []Entity {
+	return find(mentionRegex, body)
+}
+
+// IsEmail reports whether a mention refers to an email address rather than
+// a handle.
+func IsEmail(mention string) bool {
+	return strings.Contains(mention, "@")
+}
+
+func find(re *regexp.Regexp, body string) []Entity {
+	found := []Entity{}
+	for _, match := range re.FindAllStringSubmatchIndex(body, -1) {
+		start, end := match[2], match[3]
+		found = append(found, Entity{
+			Text:  strings.ToLower(body[start+1 : end]),
+			Start: utf8.RuneCountInString(body[:start]),
+			End:   utf8.RuneCountInString(body[:end]),
+		})
+	}
+	return found
+}
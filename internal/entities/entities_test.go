@@ -0,0 +1,48 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	got := Hashtags("Loving #Go and #golang_tips, not a#b")
+	want := []Entity{
+		{Text: "go", Start: 7, End: 10},
+		{Text: "golang_tips", Start: 15, End: 27},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestHashtagsUnicodeOffsets(t *testing.T) {
+	got := Hashtags("héllo #Café")
+	want := []Entity{{Text: "café", Start: 6, End: 11}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	got := Mentions("@Alice ask @bob@example.com. mail me@example.com")
+	want := []Entity{
+		{Text: "alice", Start: 0, End: 6},
+		{Text: "bob@example.com", Start: 11, End: 27},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if IsEmail(got[0].Text) || !IsEmail(got[1].Text) {
+		t.Fatalf("expected only the second mention to be an email")
+	}
+}
+
+func TestNoEntities(t *testing.T) {
+	if got := Hashtags("nothing here"); len(got) != 0 {
+		t.Fatalf("expected no hashtags, got %v", got)
+	}
+	if got := Mentions("nothing here"); len(got) != 0 {
+		t.Fatalf("expected no mentions, got %v", got)
+	}
+}
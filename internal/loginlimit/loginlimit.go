@@ -0,0 +1,71 @@
+// Package loginlimit decides how long a client has to wait before trying
+// to log in again after getting the password wrong.
+package loginlimit
+
+import "time"
+
+// Policy slows down repeated failures against one key (an email address or
+// an IP address). The first FreeAttempts failures cost nothing; after that
+// each failure doubles the wait, starting at BaseDelay and capped at
+// MaxDelay, until LockoutAfter failures lock the key for LockoutDuration.
+// A LockoutAfter of zero never locks the key, leaving only the delays.
+// Failures older than Window are forgotten.
+type Policy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// State is what has to be remembered about a key between attempts.
+type State struct {
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  time.Time
+}
+
+// RetryAfter is how long the key has to wait before its next attempt, or
+// zero if it can try now.
+func (p Policy) RetryAfter(s State, now time.Time) time.Duration {
+	if now.Before(s.LockedUntil) {
+		return s.LockedUntil.Sub(now)
+	}
+	s = p.current(s, now)
+	wait := s.LastFailedAt.Add(p.delay(s.Failures)).Sub(now)
+	return max(wait, 0)
+}
+
+// Fail records a failed attempt at now. It reports whether this failure
+// locked the key.
+func (p Policy) Fail(s State, now time.Time) (State, bool) {
+	s = p.current(s, now)
+	s.Failures++
+	s.LastFailedAt = now
+	if p.LockoutAfter > 0 && s.Failures >= p.LockoutAfter {
+		// The lockout is the end of this round; the next one starts
+		// from scratch once it is over.
+		return State{LastFailedAt: now, LockedUntil: now.Add(p.LockoutDuration)}, true
+	}
+	return s, false
+}
+
+// current forgets failures that have aged out of the window.
+func (p Policy) current(s State, now time.Time) State {
+	if now.Sub(s.LastFailedAt) > p.Window {
+		return State{LockedUntil: s.LockedUntil}
+	}
+	return s
+}
+
+func (p Policy) delay(failures int) time.Duration {
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
@@ -0,0 +1,89 @@
+package loginlimit
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        8 * time.Second,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+func TestFreeAttempts(t *testing.T) {
+	now := time.Now()
+	var s State
+	for range testPolicy.FreeAttempts {
+		s, _ = testPolicy.Fail(s, now)
+		if wait := testPolicy.RetryAfter(s, now); wait != 0 {
+			t.Fatalf("expected no wait after %d failures, got %s", s.Failures, wait)
+		}
+	}
+}
+
+func TestIncreasingDelay(t *testing.T) {
+	now := time.Now()
+	s := State{Failures: testPolicy.FreeAttempts, LastFailedAt: now}
+	for _, want := range []time.Duration{1, 2, 4, 8, 8} {
+		s, _ = testPolicy.Fail(s, now)
+		if wait := testPolicy.RetryAfter(s, now); wait != want*time.Second {
+			t.Fatalf("after %d failures expected %ss, got %s", s.Failures, want, wait)
+		}
+	}
+	if wait := testPolicy.RetryAfter(s, now.Add(5*time.Second)); wait != 3*time.Second {
+		t.Fatalf("expected the wait to count down, got %s", wait)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	now := time.Now()
+	s := State{Failures: testPolicy.LockoutAfter - 2, LastFailedAt: now}
+	s, locked := testPolicy.Fail(s, now)
+	if locked {
+		t.Fatalf("locked out too early")
+	}
+	s, locked = testPolicy.Fail(s, now)
+	if !locked {
+		t.Fatalf("expected a lockout after %d failures", testPolicy.LockoutAfter)
+	}
+	if wait := testPolicy.RetryAfter(s, now); wait != testPolicy.LockoutDuration {
+		t.Fatalf("expected to wait out the lockout, got %s", wait)
+	}
+	later := now.Add(testPolicy.LockoutDuration)
+	if wait := testPolicy.RetryAfter(s, later); wait != 0 {
+		t.Fatalf("expected the lockout to end, got %s", wait)
+	}
+	if s, _ = testPolicy.Fail(s, later); s.Failures != 1 {
+		t.Fatalf("expected counting to start over after a lockout, got %d", s.Failures)
+	}
+}
+
+func TestNoLockout(t *testing.T) {
+	p := testPolicy
+	p.LockoutAfter = 0
+	now := time.Now()
+	s := State{Failures: 100, LastFailedAt: now}
+	s, locked := p.Fail(s, now)
+	if locked || !s.LockedUntil.IsZero() {
+		t.Fatalf("expected no lockout with LockoutAfter 0")
+	}
+	if wait := p.RetryAfter(s, now); wait != p.MaxDelay {
+		t.Fatalf("expected to wait %s, got %s", p.MaxDelay, wait)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	now := time.Now()
+	s := State{Failures: testPolicy.LockoutAfter - 1, LastFailedAt: now}
+	later := now.Add(testPolicy.Window + time.Second)
+	if wait := testPolicy.RetryAfter(s, later); wait != 0 {
+		t.Fatalf("expected old failures to be forgotten, got %s", wait)
+	}
+	if s, locked := testPolicy.Fail(s, later); locked || s.Failures != 1 {
+		t.Fatalf("expected old failures to be forgotten, got %d", s.Failures)
+	}
+}
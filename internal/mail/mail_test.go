@@ -0,0 +1,78 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	data, err := format("chirpy@example.com", Message{
+		To:      "user@example.com",
+		Subject: "Verify your email",
+		Body:    "line one\nline two",
+	}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Verify your email\r\n",
+		"Date: Thu, 02 Jan 2025 03:04:05 +0000\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestFormatEncodesSubject(t *testing.T) {
+	data, err := format("chirpy@example.com", Message{To: "user@example.com", Subject: "Bienvenue à Chirpy"}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), "Subject: =?utf-8?q?") {
+		t.Fatalf("expected an encoded subject, got:\n%s", data)
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	for _, msg := range []Message{
+		{To: "user@example.com\r\nBcc: victim@example.com"},
+		{To: "user@example.com", Subject: "hi\nBcc: victim@example.com"},
+	} {
+		if _, err := format("chirpy@example.com", msg, time.Now()); err == nil {
+			t.Fatalf("expected an error for %+v", msg)
+		}
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewFileSender(dir, "chirpy@example.com")
+	for range 2 {
+		if err := sender.Send(context.Background(), Message{To: "user@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(string(data), "\r\n\r\nHello") {
+		t.Fatalf("unexpected message:\n%s", data)
+	}
+}
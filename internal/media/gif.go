@@ -0,0 +1,110 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+)
+
+// processGIF decodes and encodes the GIF again, which keeps its frames,
+// timing and looping but drops comments and application data other than
+// the loop count.
+func processGIF(data []byte, opts Options) (*Image, error) {
+	cfg, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if err = checkPixels(cfg.Width, cfg.Height, opts); err != nil {
+		return nil, err
+	}
+	if err = checkGIFFrames(data, opts); err != nil {
+		return nil, err
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	var buf bytes.Buffer
+	if err = gif.EncodeAll(&buf, g); err != nil {
+		return nil, err
+	}
+	// The thumbnail is the first frame, drawn on the full canvas since a
+	// frame can cover only part of it.
+	canvas := image.NewRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	draw.Draw(canvas, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+	var thumb bytes.Buffer
+	if err = png.Encode(&thumb, shrink(canvas, opts.ThumbnailSize)); err != nil {
+		return nil, err
+	}
+	return &Image{
+		ContentType:          GIF,
+		Data:                 buf.Bytes(),
+		Width:                cfg.Width,
+		Height:               cfg.Height,
+		Thumbnail:            thumb.Bytes(),
+		ThumbnailContentType: PNG,
+	}, nil
+}
+
+// checkGIFFrames adds up the size of every frame from the block headers,
+// before decoding any of them, and checks it against opts.MaxPixels.
+func checkGIFFrames(data []byte, opts Options) error {
+	// Header, logical screen descriptor and global colour table.
+	if len(data) < 13 {
+		return ErrInvalidImage
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&7 + 1)
+	}
+	pixels := 0
+	for pos < len(data) {
+		var err error
+		switch data[pos] {
+		case 0x21: // Extension: label, then sub-blocks.
+			pos, err = skipGIFSubBlocks(data, pos+2)
+		case 0x2C: // Image descriptor.
+			if pos+10 > len(data) {
+				return ErrInvalidImage
+			}
+			width := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			height := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			pixels += width * height
+			if pixels > opts.MaxPixels {
+				return ErrTooManyPixels
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&7 + 1)
+			}
+			// Skip the LZW code size byte.
+			pos, err = skipGIFSubBlocks(data, pos+1)
+		case 0x3B: // Trailer.
+			return nil
+		default:
+			return ErrInvalidImage
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func skipGIFSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, ErrInvalidImage
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
@@ -0,0 +1,182 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+)
+
+const (
+	jpegQuality          = 90
+	jpegThumbnailQuality = 80
+)
+
+func processJPEG(data []byte, opts Options) (*Image, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if err = checkPixels(cfg.Width, cfg.Height, opts); err != nil {
+		return nil, err
+	}
+	stripped, orientation, err := stripJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	out := &Image{ContentType: JPEG, Data: stripped, Width: cfg.Width, Height: cfg.Height}
+	// Stripping the EXIF data is lossless, but turning the image upright
+	// means encoding it again.
+	if orientation > 1 {
+		img = orient(toRGBA(img), orientation)
+		var buf bytes.Buffer
+		if err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		out.Data = buf.Bytes()
+		out.Width, out.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	}
+	var thumb bytes.Buffer
+	if err = jpeg.Encode(&thumb, shrink(toRGBA(img), opts.ThumbnailSize), &jpeg.Options{Quality: jpegThumbnailQuality}); err != nil {
+		return nil, err
+	}
+	out.Thumbnail, out.ThumbnailContentType = thumb.Bytes(), JPEG
+	return out, nil
+}
+
+// stripJPEG drops the segments that hold metadata (EXIF, XMP, IPTC,
+// comments and the like) and anything after the end of the image. It keeps
+// JFIF headers, ICC colour profiles and Adobe colour transforms, which
+// change how the image looks. It also returns the EXIF orientation, 1 if
+// there is none.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, ErrInvalidImage
+	}
+	out := []byte{0xFF, 0xD8}
+	orientation := 1
+	pos := 2
+	for {
+		if pos >= len(data) || data[pos] != 0xFF {
+			return nil, 0, ErrInvalidImage
+		}
+		start := pos
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		marker := data[pos]
+		pos++
+		if marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 {
+			out = append(out, data[start:pos]...)
+			continue
+		}
+		if marker == 0xD9 || pos+2 > len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		end := pos + int(binary.BigEndian.Uint16(data[pos:]))
+		if end > len(data) || end < pos+2 {
+			return nil, 0, ErrInvalidImage
+		}
+		payload := data[pos+2 : end]
+		if marker == 0xDA {
+			// Start of scan: the rest is image data up to the end marker.
+			// 0xFF bytes in image data are always followed by 0x00 or a
+			// restart marker, so the first FF D9 is the real end.
+			eoi := bytes.Index(data[end:], []byte{0xFF, 0xD9})
+			if eoi < 0 {
+				return nil, 0, ErrInvalidImage
+			}
+			out = append(out, data[start:end+eoi+2]...)
+			return out, orientation, nil
+		}
+		switch {
+		case marker == 0xE1:
+			if o := exifOrientation(payload); o != 1 {
+				orientation = o
+			}
+		case marker == 0xE2 && !bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")):
+		case marker >= 0xE3 && marker <= 0xED, marker == 0xEF, marker == 0xFE:
+		default:
+			out = append(out, data[start:end]...)
+		}
+		pos = end
+	}
+}
+
+// exifOrientation reads the orientation tag from the first IFD of an APP1
+// EXIF payload, returning 1 (upright) if it can't.
+func exifOrientation(payload []byte) int {
+	tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient turns an image stored with an EXIF orientation upright.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], src.Pix[src.PixOffset(sx+src.Rect.Min.X, sy+src.Rect.Min.Y):])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,71 @@
+// Package media checks uploaded images, strips the metadata that can give
+// away where and on what they were taken, and makes thumbnails.
+package media
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The image types that can be uploaded.
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	GIF  = "image/gif"
+	WebP = "image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("only JPEG, PNG, GIF and WebP images are supported")
+	ErrTooManyPixels   = errors.New("image has too many pixels")
+	ErrInvalidImage    = errors.New("image is damaged or not what it claims to be")
+)
+
+// Options limit and shape what Process does.
+type Options struct {
+	// MaxPixels bounds width*height, summed over the frames of an
+	// animation, so a small file can't decompress into a huge image.
+	MaxPixels int
+	// ThumbnailSize is the longest side of a thumbnail in pixels.
+	ThumbnailSize int
+}
+
+// Image is an upload ready to be stored.
+type Image struct {
+	ContentType          string
+	Data                 []byte
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Process works out what type of image data is from its content, whatever
+// the client claimed, and returns it without metadata and with a
+// thumbnail. JPEGs rotated by their EXIF orientation are turned upright
+// first, since the orientation goes with the rest of the EXIF data.
+func Process(data []byte, opts Options) (*Image, error) {
+	switch http.DetectContentType(data) {
+	case JPEG:
+		return processJPEG(data, opts)
+	case PNG:
+		return processPNG(data, opts)
+	case GIF:
+		return processGIF(data, opts)
+	case WebP:
+		return processWebP(data, opts)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+func checkPixels(width, height int, opts Options) error {
+	if width <= 0 || height <= 0 {
+		return ErrInvalidImage
+	}
+	if width > opts.MaxPixels/height {
+		return fmt.Errorf("%w: %dx%d is over the limit of %d", ErrTooManyPixels, width, height, opts.MaxPixels)
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var testOptions = Options{MaxPixels: 1 << 20, ThumbnailSize: 8}
+
+// halves is a w x h image, red on the left and blue on the right.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// exifSegment is an APP1 segment holding just an orientation tag.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	payload := append([]byte("Exif\x00\x00"), append(append(tiff, entry...), 0, 0, 0, 0)...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("encoding JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withSegments inserts segments after the start of image marker, and junk
+// after the end.
+func withSegments(jpegData []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, jpegData[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	out = append(out, jpegData[2:]...)
+	return append(out, "trailing junk"...)
+}
+
+func TestJPEGStripsMetadataLosslessly(t *testing.T) {
+	original := encodeJPEG(t, halves(16, 8))
+	comment := append([]byte{0xFF, 0xFE, 0, 8}, "secret"...)
+	got, err := Process(withSegments(original, exifSegment(1), comment), testOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if !bytes.Equal(got.Data, original) {
+		t.Errorf("stripped JPEG differs from the original")
+	}
+	if got.ContentType != JPEG || got.Width != 16 || got.Height != 8 {
+		t.Errorf("got %s %dx%d, want image/jpeg 16x8", got.ContentType, got.Width, got.Height)
+	}
+	if got.ThumbnailContentType != JPEG {
+		t.Errorf("thumbnail is %s, want image/jpeg", got.ThumbnailContentType)
+	}
+}
+
+func TestJPEGAppliesOrientation(t *testing.T) {
+	// 6 means the camera was turned clockwise, so the red left half ends
+	// up on top.
+	got, err := Process(withSegments(encodeJPEG(t, halves(16, 8)), exifSegment(6)), testOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if bytes.Contains(got.Data, []byte("Exif")) {
+		t.Errorf("EXIF data wasn't removed")
+	}
+	if got.Width != 8 || got.Height != 16 {
+		t.Fatalf("got %dx%d, want 8x16", got.Width, got.Height)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("decoding result: %v", err)
+	}
+	top, bottom := color.RGBAModel.Convert(img.At(4, 2)).(color.RGBA), color.RGBAModel.Convert(img.At(4, 13)).(color.RGBA)
+	if top.R < 200 || top.B > 50 || bottom.B < 200 || bottom.R > 50 {
+		t.Errorf("top is %v and bottom is %v, want red over blue", top, bottom)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Pix[0] = 1 // Mark the top left pixel.
+	// Where the marked pixel ends up for each orientation.
+	want := map[int]image.Point{
+		1: {0, 0}, 2: {2, 0}, 3: {2, 1}, 4: {0, 1},
+		5: {0, 0}, 6: {1, 0}, 7: {1, 2}, 8: {0, 2},
+	}
+	for orientation, p := range want {
+		dst := orient(src, orientation)
+		if dst.Pix[dst.PixOffset(p.X, p.Y)] != 1 {
+			t.Errorf("orientation %d: marked pixel isn't at %v", orientation, p)
+		}
+	}
+}
+
+func TestPNGStripsMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves(16, 8)); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	data := buf.Bytes()
+	iend := len(data) - 12
+	text := pngChunk("tEXt", []byte("Author\x00someone"))
+	data = append(append(append([]byte{}, data[:iend]...), text...), data[iend:]...)
+	got, err := Process(data, testOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if bytes.Contains(got.Data, []byte("someone")) {
+		t.Errorf("tEXt chunk wasn't removed")
+	}
+	if _, err = png.Decode(bytes.NewReader(got.Data)); err != nil {
+		t.Errorf("stripped PNG doesn't decode: %v", err)
+	}
+	thumb, err := png.Decode(bytes.NewReader(got.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail doesn't decode: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 8 || b.Dy() != 4 {
+		t.Errorf("thumbnail is %dx%d, want 8x4", b.Dx(), b.Dy())
+	}
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(append(chunk, chunkType...), data...)
+	// The CRC isn't checked for ancillary chunks.
+	return append(chunk, 0, 0, 0, 0)
+}
+
+func testGIF(t *testing.T, frames int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	g := &gif.GIF{LoopCount: 3}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 10, 10), palette))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("encoding GIF: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGIFKeepsFramesAndDropsComments(t *testing.T) {
+	data := testGIF(t, 2)
+	comment := append([]byte{0x21, 0xFE, 6}, "secret\x00"...)
+	data = append(append(data[:len(data)-1:len(data)-1], comment...), 0x3B)
+	got, err := Process(data, testOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if bytes.Contains(got.Data, []byte("secret")) {
+		t.Errorf("comment wasn't removed")
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("result doesn't decode: %v", err)
+	}
+	if len(g.Image) != 2 || g.LoopCount != 3 {
+		t.Errorf("got %d frames looping %d times, want 2 looping 3", len(g.Image), g.LoopCount)
+	}
+}
+
+func TestGIFFrameLimit(t *testing.T) {
+	_, err := Process(testGIF(t, 20), Options{MaxPixels: 1000, ThumbnailSize: 8})
+	if !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("20 frames of 100 pixels gave %v, want ErrTooManyPixels", err)
+	}
+}
+
+func webpChunk(fourCC string, data []byte) []byte {
+	chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// solidVP8L is a lossless bitstream for a w x h image all of one colour.
+// Each of its prefix codes has a single symbol, so the pixels take no bits.
+func solidVP8L(w, h int, c color.NRGBA) []byte {
+	out := []byte{0x2F}
+	var bits uint64
+	var n uint
+	put := func(v uint64, width uint) {
+		bits |= v << n
+		for n += width; n >= 8; n -= 8 {
+			out = append(out, byte(bits))
+			bits >>= 8
+		}
+	}
+	put(uint64(w-1), 14)
+	put(uint64(h-1), 14)
+	put(1, 1) // Alpha is used.
+	put(0, 3) // Version.
+	put(0, 1) // No transforms.
+	put(0, 1) // No colour cache.
+	put(0, 1) // No meta prefix codes.
+	for _, symbol := range []uint8{c.G, c.R, c.B, c.A, 0} {
+		// A simple code of one 8 bit symbol.
+		put(1, 1)
+		put(0, 1)
+		put(1, 1)
+		put(uint64(symbol), 8)
+	}
+	return append(out, byte(bits), 0, 0)
+}
+
+func riffWebP(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(data, body...)
+}
+
+// webpFrame is an ANMF chunk holding a lossless frame at (x, y), which
+// have to be even.
+func webpFrame(x, y, w, h int, vp8l []byte) []byte {
+	header := make([]byte, 16)
+	put24 := func(at, v int) {
+		header[at], header[at+1], header[at+2] = byte(v), byte(v>>8), byte(v>>16)
+	}
+	put24(0, x/2)
+	put24(3, y/2)
+	put24(6, w-1)
+	put24(9, h-1)
+	put24(12, 100)
+	return webpChunk("ANMF", append(header, webpChunk("VP8L", vp8l)...))
+}
+
+func thumbnailColor(t *testing.T, img *Image) color.NRGBA {
+	t.Helper()
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail doesn't decode: %v", err)
+	}
+	return color.NRGBAModel.Convert(thumb.At(0, 0)).(color.NRGBA)
+}
+
+func TestWebPStripsMetadata(t *testing.T) {
+	// A 100x50 image that claims EXIF and XMP data.
+	red := color.NRGBA{R: 255, A: 255}
+	vp8x := []byte{webpFlagEXIF | webpFlagXMP, 0, 0, 0, 99, 0, 0, 49, 0, 0}
+	data := riffWebP(
+		webpChunk("VP8X", vp8x),
+		webpChunk("VP8L", solidVP8L(100, 50, red)),
+		webpChunk("EXIF", []byte("where")),
+		webpChunk("XMP ", []byte("who")),
+	)
+
+	got, err := Process(data, testOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got.ContentType != WebP || got.Width != 100 || got.Height != 50 {
+		t.Errorf("got %s %dx%d, want image/webp 100x50", got.ContentType, got.Width, got.Height)
+	}
+	if bytes.Contains(got.Data, []byte("where")) || bytes.Contains(got.Data, []byte("who")) {
+		t.Errorf("metadata chunks weren't removed")
+	}
+	if flags := got.Data[20]; flags&(webpFlagEXIF|webpFlagXMP) != 0 {
+		t.Errorf("VP8X flags %#x still claim metadata", flags)
+	}
+	if size := int(binary.LittleEndian.Uint32(got.Data[4:])); size != len(got.Data)-8 {
+		t.Errorf("RIFF size is %d, want %d", size, len(got.Data)-8)
+	}
+	if c := thumbnailColor(t, got); c != red {
+		t.Errorf("thumbnail is %v, want %v", c, red)
+	}
+}
+
+func TestWebPRejectsUndecodableImages(t *testing.T) {
+	// A valid header for a 100x50 image, followed by nothing that decodes.
+	data := riffWebP(webpChunk("VP8L", []byte{0x2F, 99, 0x40, 0x0C, 0, 0}))
+	if _, err := Process(data, testOptions); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("got %v, want ErrInvalidImage", err)
+	}
+}
+
+func TestWebPAnimation(t *testing.T) {
+	red, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	vp8x := []byte{webpFlagAnimation, 0, 0, 0, 7, 0, 0, 7, 0, 0}
+	data := riffWebP(
+		webpChunk("VP8X", vp8x),
+		webpChunk("ANIM", make([]byte, 6)),
+		webpFrame(0, 0, 8, 8, solidVP8L(8, 8, red)),
+		webpFrame(2, 2, 4, 4, solidVP8L(4, 4, blue)),
+	)
+	got, err := Process(data, testOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got.Width != 8 || got.Height != 8 {
+		t.Errorf("got %dx%d, want 8x8", got.Width, got.Height)
+	}
+	if c := thumbnailColor(t, got); c != red {
+		t.Errorf("thumbnail is %v, want the first frame's %v", c, red)
+	}
+	// The canvas fits, but not the frames on top of it.
+	if _, err = Process(data, Options{MaxPixels: 70, ThumbnailSize: 8}); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("80 pixels of frames gave %v, want ErrTooManyPixels", err)
+	}
+	// A second frame with a valid header and nothing that decodes.
+	broken := riffWebP(
+		webpChunk("VP8X", vp8x),
+		webpChunk("ANIM", make([]byte, 6)),
+		webpFrame(0, 0, 8, 8, solidVP8L(8, 8, red)),
+		webpFrame(0, 0, 8, 8, []byte{0x2F, 0x07, 0xC0, 0x01, 0, 0}),
+	)
+	if _, err = Process(broken, testOptions); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("an undecodable frame gave %v, want ErrInvalidImage", err)
+	}
+}
+
+func TestRejectsOtherTypes(t *testing.T) {
+	for _, data := range [][]byte{[]byte("hello, world"), []byte("<svg xmlns='http://www.w3.org/2000/svg'/>"), []byte("%PDF-1.7")} {
+		if _, err := Process(data, testOptions); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Process(%q) gave %v, want ErrUnsupportedType", data, err)
+		}
+	}
+}
+
+func TestRejectsTooManyPixels(t *testing.T) {
+	_, err := Process(encodeJPEG(t, halves(64, 64)), Options{MaxPixels: 1000, ThumbnailSize: 8})
+	if !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("64x64 image gave %v, want ErrTooManyPixels", err)
+	}
+}
+
+func TestRejectsTruncatedImages(t *testing.T) {
+	data := encodeJPEG(t, halves(16, 8))
+	if _, err := Process(data[:len(data)/2], testOptions); err == nil {
+		t.Errorf("truncated JPEG was accepted")
+	}
+}
+
+func TestShrink(t *testing.T) {
+	for _, tc := range []struct{ w, h, size, wantW, wantH int }{
+		{1000, 500, 320, 320, 160},
+		{500, 1000, 320, 160, 320},
+		{100, 50, 320, 100, 50},
+		{1000, 1, 320, 320, 1},
+	} {
+		got := shrink(image.NewRGBA(image.Rect(0, 0, tc.w, tc.h)), tc.size).Bounds()
+		if got.Dx() != tc.wantW || got.Dy() != tc.wantH {
+			t.Errorf("shrink %dx%d to %d gave %dx%d, want %dx%d", tc.w, tc.h, tc.size, got.Dx(), got.Dy(), tc.wantW, tc.wantH)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks hold text, timestamps and EXIF data, none of which
+// changes how the image looks.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func processPNG(data []byte, opts Options) (*Image, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if err = checkPixels(cfg.Width, cfg.Height, opts); err != nil {
+		return nil, err
+	}
+	stripped, err := stripPNG(data)
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	var thumb bytes.Buffer
+	if err = png.Encode(&thumb, shrink(toRGBA(img), opts.ThumbnailSize)); err != nil {
+		return nil, err
+	}
+	return &Image{
+		ContentType:          PNG,
+		Data:                 stripped,
+		Width:                cfg.Width,
+		Height:               cfg.Height,
+		Thumbnail:            thumb.Bytes(),
+		ThumbnailContentType: PNG,
+	}, nil
+}
+
+// stripPNG drops metadata chunks and anything after the IEND chunk.
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrInvalidImage
+	}
+	out := append([]byte{}, pngSignature...)
+	pos := len(pngSignature)
+	for {
+		if pos+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		// Length, type, data, then a CRC.
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end > len(data) || end < pos {
+			return nil, ErrInvalidImage
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[pos:end]...)
+		}
+		if chunkType == "IEND" {
+			return out, nil
+		}
+		pos = end
+	}
+}
@@ -0,0 +1,184 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/png"
+
+	"golang.org/x/image/webp"
+)
+
+// VP8X flags.
+const (
+	webpFlagAlpha     = 0x10
+	webpFlagEXIF      = 0x08
+	webpFlagXMP       = 0x04
+	webpFlagAnimation = 0x02
+)
+
+// processWebP drops the EXIF and XMP chunks from a WebP file, then decodes
+// it to check it and make the thumbnail. Every frame of an animation is
+// decoded, and counts towards opts.MaxPixels.
+func processWebP(data []byte, opts Options) (*Image, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidImage
+	}
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd > len(data) || riffEnd < 12 {
+		return nil, ErrInvalidImage
+	}
+	data = data[:riffEnd]
+	out := append([]byte{}, data[:12]...)
+	flagsAt := -1
+	var width, height int
+	var animated bool
+	var frames [][]byte
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size
+		// Chunks are padded to an even length.
+		next := end + size&1
+		if end > len(data) || end < pos || next > len(data) {
+			return nil, ErrInvalidImage
+		}
+		payload := data[pos+8 : end]
+		switch fourCC {
+		case "EXIF", "XMP ":
+			pos = next
+			continue
+		case "VP8X":
+			if size < 10 {
+				return nil, ErrInvalidImage
+			}
+			flagsAt = len(out) + 8
+			animated = payload[0]&webpFlagAnimation != 0
+			width = 1 + webpUint24(payload[4:])
+			height = 1 + webpUint24(payload[7:])
+		case "ANMF":
+			frames = append(frames, payload)
+		case "VP8 ":
+			// A lossy key frame: a 3 byte tag, a start code, then 14 bit
+			// dimensions.
+			if width == 0 {
+				if size < 10 || payload[3] != 0x9D || payload[4] != 0x01 || payload[5] != 0x2A {
+					return nil, ErrInvalidImage
+				}
+				width = int(binary.LittleEndian.Uint16(payload[6:]) & 0x3FFF)
+				height = int(binary.LittleEndian.Uint16(payload[8:]) & 0x3FFF)
+			}
+		case "VP8L":
+			// Lossless: a signature byte, then 14 bits each of width-1 and
+			// height-1.
+			if width == 0 {
+				if size < 5 || payload[0] != 0x2F {
+					return nil, ErrInvalidImage
+				}
+				bits := binary.LittleEndian.Uint32(payload[1:])
+				width = int(bits&0x3FFF) + 1
+				height = int(bits>>14&0x3FFF) + 1
+			}
+		}
+		out = append(out, data[pos:next]...)
+		pos = next
+	}
+	if err := checkPixels(width, height, opts); err != nil {
+		return nil, err
+	}
+	if flagsAt >= 0 {
+		out[flagsAt] &^= webpFlagEXIF | webpFlagXMP
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+
+	var thumbSource *image.RGBA
+	if animated {
+		canvas, err := decodeWebPFrames(frames, width, height, opts)
+		if err != nil {
+			return nil, err
+		}
+		thumbSource = canvas
+	} else {
+		img, err := webp.Decode(bytes.NewReader(out))
+		if err != nil || img.Bounds().Dx() != width || img.Bounds().Dy() != height {
+			return nil, ErrInvalidImage
+		}
+		thumbSource = toRGBA(img)
+	}
+	var thumb bytes.Buffer
+	if err := png.Encode(&thumb, shrink(thumbSource, opts.ThumbnailSize)); err != nil {
+		return nil, err
+	}
+	return &Image{
+		ContentType:          WebP,
+		Data:                 out,
+		Width:                width,
+		Height:               height,
+		Thumbnail:            thumb.Bytes(),
+		ThumbnailContentType: PNG,
+	}, nil
+}
+
+// decodeWebPFrames decodes every frame of an animation, after checking
+// from their headers that they fit on the canvas and add up to no more
+// than opts.MaxPixels. It returns the first frame drawn on the canvas, for
+// the thumbnail.
+func decodeWebPFrames(frames [][]byte, width, height int, opts Options) (*image.RGBA, error) {
+	if len(frames) == 0 {
+		return nil, ErrInvalidImage
+	}
+	// Each frame starts with its offset, halved, and its size, less one,
+	// then a duration and flags.
+	bounds := make([]image.Rectangle, len(frames))
+	pixels := 0
+	for i, frame := range frames {
+		if len(frame) < 16 {
+			return nil, ErrInvalidImage
+		}
+		x, y := 2*webpUint24(frame[0:]), 2*webpUint24(frame[3:])
+		bounds[i] = image.Rect(x, y, x+1+webpUint24(frame[6:]), y+1+webpUint24(frame[9:]))
+		if !bounds[i].In(image.Rect(0, 0, width, height)) {
+			return nil, ErrInvalidImage
+		}
+		pixels += bounds[i].Dx() * bounds[i].Dy()
+		if pixels > opts.MaxPixels {
+			return nil, ErrTooManyPixels
+		}
+	}
+	var canvas *image.RGBA
+	for i, frame := range frames {
+		b := bounds[i]
+		img, err := webp.Decode(bytes.NewReader(webpFrameFile(frame[16:], b.Dx(), b.Dy())))
+		if err != nil || img.Bounds().Dx() != b.Dx() || img.Bounds().Dy() != b.Dy() {
+			return nil, ErrInvalidImage
+		}
+		if i == 0 {
+			canvas = image.NewRGBA(image.Rect(0, 0, width, height))
+			draw.Draw(canvas, b, img, img.Bounds().Min, draw.Over)
+		}
+	}
+	return canvas, nil
+}
+
+// webpFrameFile wraps the chunks of one animation frame up as a WebP file
+// of their own, which is the only way the decoder takes them.
+func webpFrameFile(chunks []byte, width, height int) []byte {
+	vp8x := make([]byte, 10)
+	if bytes.HasPrefix(chunks, []byte("ALPH")) {
+		vp8x[0] = webpFlagAlpha
+	}
+	vp8x[4], vp8x[5], vp8x[6] = byte(width-1), byte((width-1)>>8), byte((width-1)>>16)
+	vp8x[7], vp8x[8], vp8x[9] = byte(height-1), byte((height-1)>>8), byte((height-1)>>16)
+	body := append([]byte("WEBPVP8X\x0a\x00\x00\x00"), vp8x...)
+	body = append(body, chunks...)
+	file := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(file, body...)
+}
+
+func webpUint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
@@ -0,0 +1,95 @@
+// Package passwordpolicy checks new passwords against the rules Chirpy
+// requires of them.
+package passwordpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBytes is the longest password bcrypt can hash. Longer ones would be
+// silently truncated by some implementations, so they are refused instead.
+const MaxBytes = 72
+
+// Rule names, as reported in Violation.Rule.
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxBytes     = "max_bytes"
+	RuleBanned       = "banned"
+	RuleMatchesEmail = "matches_email"
+)
+
+// Violation is one rule a password breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Policy struct {
+	// MinLength is counted in characters, not bytes.
+	MinLength int
+	banned    map[string]struct{}
+}
+
+func New(minLength int) *Policy {
+	return &Policy{MinLength: minLength, banned: map[string]struct{}{}}
+}
+
+// LoadBanned adds the passwords in r, one per line, to the banned list.
+// Blank lines and lines starting with # are skipped. Matching ignores case.
+func (p *Policy) LoadBanned(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.banned[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// LoadBannedFile is LoadBanned for a file on disk.
+func (p *Policy) LoadBannedFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return p.LoadBanned(file)
+}
+
+// Check returns every rule password breaks for the account with the given
+// email, or nothing if it is acceptable.
+func (p *Policy) Check(password, email string) []Violation {
+	violations := []Violation{}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if len(password) > MaxBytes {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxBytes,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", MaxBytes),
+		})
+	}
+	if _, ok := p.banned[strings.ToLower(password)]; ok {
+		violations = append(violations, Violation{
+			Rule:    RuleBanned,
+			Message: "Password is too common",
+		})
+	}
+	if email != "" && strings.EqualFold(password, email) {
+		violations = append(violations, Violation{
+			Rule:    RuleMatchesEmail,
+			Message: "Password must not be the same as the email address",
+		})
+	}
+	return violations
+}
@@ -0,0 +1,41 @@
+package passwordpolicy
+
+import (
+	"strings"
+	"testing"
+)
+
+func rules(violations []Violation) []string {
+	names := []string{}
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestCheck(t *testing.T) {
+	policy := New(8)
+	if err := policy.LoadBanned(strings.NewReader("# common\nPassword1\n\nletmein\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"correct horse battery", []string{}},
+		{"", []string{RuleMinLength}},
+		{"short", []string{RuleMinLength}},
+		{"pässwörd", []string{}},
+		{strings.Repeat("a", 73), []string{RuleMaxBytes}},
+		{strings.Repeat("é", 37), []string{RuleMaxBytes}},
+		{"PASSWORD1", []string{RuleBanned}},
+		{"letmein", []string{RuleMinLength, RuleBanned}},
+		{"User@Example.com", []string{RuleMatchesEmail}},
+	}
+	for _, tt := range tests {
+		got := rules(policy.Check(tt.password, "user@example.com"))
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package profanity
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+)
+
+type Action string
+
+const (
+	// Mask replaces the word with asterisks.
+	Mask Action = "mask"
+	// Reject refuses the whole chirp.
+	Reject Action = "reject"
+	// Flag lets the chirp through unchanged but marks it for review.
+	Flag Action = "flag"
+)
+
+const maskText = "****"
+
+func ParseAction(s string) (Action, error) {
+	switch Action(s) {
+	case Mask, Reject, Flag:
+		return Action(s), nil
+	}
+	return "", fmt.Errorf("action must be one of mask, reject or flag")
+}
+
+// Filter matches whole words regardless of case or surrounding punctuation,
+// so "Kerfuffle!" matches the word "kerfuffle".
+type Filter struct {
+	words map[string]Action
+}
+
+type Result struct {
+	Body     string
+	Rejected []string
+	Flagged  []string
+}
+
+func NewFilter(words map[string]Action) Filter {
+	folded := map[string]Action{}
+	for word, action := range words {
+		folded[Fold(word)] = action
+	}
+	return Filter{words: folded}
+}
+
+// Apply masks the words set to Mask and reports the ones set to Reject or
+// Flag. Everything other than masked words is kept exactly as written.
+func (f Filter) Apply(body string) Result {
+	result := Result{}
+	var out strings.Builder
+	runes := []rune(body)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			out.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		switch f.words[Fold(word)] {
+		case Mask:
+			out.WriteString(maskText)
+		case Reject:
+			result.Rejected = append(result.Rejected, word)
+			out.WriteString(word)
+		case Flag:
+			result.Flagged = append(result.Flagged, word)
+			out.WriteString(word)
+		default:
+			out.WriteString(word)
+		}
+		i = j
+	}
+	result.Body = out.String()
+	return result
+}
+
+// Fold maps a word to a canonical case so that words differing only by case
+// compare equal. It uses full Unicode case folding, which can change a
+// word's length: "Straße" and "STRASSE" both fold to "strasse".
+func Fold(word string) string {
+	// A Caser keeps state, so each call needs its own.
+	return cases.Fold().String(word)
+}
+
+// IsWord reports whether s is a single word the filter is able to match.
+func IsWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,58 @@
+package profanity
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testFilter = NewFilter(map[string]Action{
+	"kerfuffle": Mask,
+	"Sharbert":  Mask,
+	"fornax":    Reject,
+	"straße":    Flag,
+})
+
+func TestMaskPunctuation(t *testing.T) {
+	got := testFilter.Apply("What a kerfuffle! Sharbert, please.")
+	if got.Body != "What a ****! ****, please." {
+		t.Fatalf("expected masked body, got %q", got.Body)
+	}
+}
+
+func TestMaskKeepsWhitespace(t *testing.T) {
+	got := testFilter.Apply("  KERFUFFLE\tok ")
+	if got.Body != "  ****\tok " {
+		t.Fatalf("expected whitespace to be kept, got %q", got.Body)
+	}
+}
+
+func TestNoPartialMatches(t *testing.T) {
+	got := testFilter.Apply("kerfuffled sharberts")
+	if got.Body != "kerfuffled sharberts" {
+		t.Fatalf("expected body unchanged, got %q", got.Body)
+	}
+}
+
+func TestRejectAndFlag(t *testing.T) {
+	got := testFilter.Apply("Fornax in the STRASSE and the Straße")
+	if !reflect.DeepEqual(got.Rejected, []string{"Fornax"}) {
+		t.Fatalf("expected Fornax to be rejected, got %v", got.Rejected)
+	}
+	if !reflect.DeepEqual(got.Flagged, []string{"STRASSE", "Straße"}) {
+		t.Fatalf("expected STRASSE and Straße to be flagged, got %v", got.Flagged)
+	}
+}
+
+func TestUnicodeCaseFolding(t *testing.T) {
+	filter := NewFilter(map[string]Action{"ΣΊΣΥΦΟΣ": Mask})
+	got := filter.Apply("σίσυφος.")
+	if got.Body != "****." {
+		t.Fatalf("expected masked body, got %q", got.Body)
+	}
+}
+
+func TestIsWord(t *testing.T) {
+	if !IsWord("kerfuffle") || IsWord("two words") || IsWord("bang!") || IsWord("") {
+		t.Fatalf("IsWord gave the wrong answer")
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testStorage puts, reads back and deletes an object.
+func testStorage(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.Put(ctx, "media/abc", "image/png", []byte("first")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s.Put(ctx, "media/abc", "image/png", []byte("second")); err != nil {
+		t.Fatalf("Put over an existing object: %v", err)
+	}
+	obj, err := s.Get(ctx, "media/abc")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if err != nil {
+		t.Fatalf("reading object: %v", err)
+	}
+	if string(data) != "second" || obj.Size != int64(len(data)) {
+		t.Errorf("Get returned %q of size %d, want %q", data, obj.Size, "second")
+	}
+	if err = s.Delete(ctx, "media/abc"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err = s.Get(ctx, "media/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete gave %v, want ErrNotFound", err)
+	}
+	if err = s.Delete(ctx, "media/abc"); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	testStorage(t, NewLocal(t.TempDir()))
+}
+
+func TestLocalObjectSeeks(t *testing.T) {
+	s := NewLocal(t.TempDir())
+	ctx := context.Background()
+	if err := s.Put(ctx, "a", "text/plain", []byte("hello")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	obj, err := s.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer obj.Body.Close()
+	if _, ok := obj.Body.(io.Seeker); !ok {
+		t.Errorf("local object body isn't an io.Seeker")
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"a", "media/abc-123", "a/b/c.png", "x_y"} {
+		if err := validateKey(key); err != nil {
+			t.Errorf("validateKey(%q) = %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "/a", "a/", "a//b", "../a", "a/../b", "a/./b", "a b", "a%2f", `a\b`} {
+		if err := validateKey(key); err == nil {
+			t.Errorf("validateKey(%q) accepted it", key)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from the RFC 6238 test vectors.
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFCVectors(t *testing.T) {
+	// The RFC lists eight digit codes; six digit codes are their last six.
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("at %d: expected %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step, ok := Validate(rfcSecret, code, now.Add(period*time.Second))
+	if !ok || step != Step(now) {
+		t.Fatalf("expected a code from one step ago to validate")
+	}
+	if _, ok := Validate(rfcSecret, code, now.Add(3*period*time.Second)); ok {
+		t.Fatalf("expected an old code to fail")
+	}
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(rfcSecret, bad, now); ok {
+			t.Fatalf("expected %q to fail", bad)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = Code(secret, 1); err != nil {
+		t.Fatalf("expected a usable secret, got %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Chirpy", "user@example.com", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:user@example.com?") {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+	for _, want := range []string{"secret=ABC", "issuer=Chirpy", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Fatalf("expected %s in %s", want, uri)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(code) != 11 || code[5] != '-' {
+		t.Fatalf("unexpected recovery code %q", code)
+	}
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+	if NormalizeRecoveryCode(typed) != NormalizeRecoveryCode(code) {
+		t.Fatalf("expected %q to normalize like %q", typed, code)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/snowkittyselene/chirpy/internal/auth"
+)
+
+// loadKeys reads the access token signing keys from keysDir. Without a keys
+// directory it falls back to signing with TOKEN_SECRET, which other services
+// can't verify without being given the secret. One of them has to be set:
+// tokens signed with an empty secret could be forged by anyone.
+func loadKeys(keysDir, signingKeyID, secret string) (*auth.KeySet, error) {
+	if keysDir == "" {
+		if secret == "" {
+			return nil, errors.New("JWT_KEYS_DIR or TOKEN_SECRET must be set")
+		}
+		log.Print("JWT_KEYS_DIR is not set, signing access tokens with TOKEN_SECRET")
+		return auth.NewHMACKeySet(secret), nil
+	}
+	return auth.LoadKeySet(keysDir, signingKeyID)
+}
+
+// handlerJWKS publishes the keys access tokens can be verified with.
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.keys.JWKS())
+}
@@ -0,0 +1,12 @@
+package main
+
+import "testing"
+
+func TestLoadKeysRequiresSecret(t *testing.T) {
+	if _, err := loadKeys("", "", ""); err == nil {
+		t.Fatalf("expected an error without JWT_KEYS_DIR or TOKEN_SECRET")
+	}
+	if _, err := loadKeys("", "", "secret"); err != nil {
+		t.Fatalf("expected TOKEN_SECRET to be enough, got %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/loginlimit"
+)
+
+// Failed logins are limited per email address, so one account can't be
+// guessed at from many addresses, and per IP address, so one client can't
+// spray guesses across many accounts. IPs get more slack since many users
+// can share one.
+var (
+	emailLoginPolicy = loginlimit.Policy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	ipLoginPolicy = loginlimit.Policy{
+		FreeAttempts:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockoutAfter:    50,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+)
+
+// LoginLockout is an entry in the lockout audit log.
+type LoginLockout struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	IPAddress   string    `json:"ip_address"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+type loginThrottle struct {
+	kind    string
+	subject string
+	policy  loginlimit.Policy
+}
+
+func (t loginThrottle) key() string {
+	return t.kind + ":" + t.subject
+}
+
+// Unknown emails are throttled exactly like real ones, so the throttling
+// doesn't reveal which accounts exist.
+func loginThrottles(email, ip string) []loginThrottle {
+	return []loginThrottle{
+		{kind: "email", subject: strings.ToLower(strings.TrimSpace(email)), policy: emailLoginPolicy},
+		{kind: "ip", subject: ip, policy: ipLoginPolicy},
+	}
+}
+
+// loginAttempt is one go at something throttled, such as logging in. The
+// throttles aren't locked while the credentials are checked, so a slow
+// password hash doesn't hold up other attempts against the same email or
+// IP. Instead fail and succeed lock them just long enough to record the
+// outcome, and first check that nothing recorded since the attempt
+// started means it should have waited. Without that, a burst of guesses
+// sent at once would all find nothing to wait for. An attempt that should
+// have waited records nothing and gets the wait back, and its caller
+// answers 429 whatever the outcome was, so the guess tells the client
+// nothing.
+type loginAttempt struct {
+	cfg       *apiConfig
+	throttles []loginThrottle
+	ip        string
+}
+
+// startLoginAttempt returns the attempt, or how long the client has to wait
+// before it may try if that is more than zero.
+func (cfg *apiConfig) startLoginAttempt(ctx context.Context, throttles []loginThrottle, ip string) (*loginAttempt, time.Duration, error) {
+	now := time.Now().UTC()
+	var wait time.Duration
+	for _, t := range throttles {
+		row, err := cfg.db.GetLoginThrottle(ctx, t.key())
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		wait = max(wait, t.policy.RetryAfter(toLimitState(row), now))
+	}
+	if wait > 0 {
+		return nil, wait, nil
+	}
+	// Locking in key order keeps two attempts from waiting on each other.
+	throttles = slices.Clone(throttles)
+	slices.SortFunc(throttles, func(a, b loginThrottle) int {
+		return strings.Compare(a.key(), b.key())
+	})
+	return &loginAttempt{cfg: cfg, throttles: throttles, ip: ip}, 0, nil
+}
+
+// lockedThrottles are the throttles of an attempt, locked to record its
+// outcome.
+type lockedThrottles struct {
+	tx   *sql.Tx
+	q    *database.Queries
+	rows map[string]database.LoginThrottle
+	now  time.Time
+	// wait is how long the attempt should have waited, given everything
+	// recorded up to now.
+	wait time.Duration
+}
+
+// lock starts the transaction that records the outcome of the attempt.
+// The caller has to roll it back or commit it.
+func (a *loginAttempt) lock(ctx context.Context) (*lockedThrottles, error) {
+	tx, err := a.cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	l := &lockedThrottles{
+		tx:   tx,
+		q:    a.cfg.db.WithTx(tx),
+		rows: map[string]database.LoginThrottle{},
+		now:  time.Now().UTC(),
+	}
+	for _, t := range a.throttles {
+		if err = l.q.EnsureLoginThrottle(ctx, database.EnsureLoginThrottleParams{
+			ThrottleKey:  t.key(),
+			LastFailedAt: l.now,
+		}); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		row, err := l.q.GetLoginThrottleForUpdate(ctx, t.key())
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		l.rows[t.key()] = row
+		l.wait = max(l.wait, t.policy.RetryAfter(toLimitState(row), l.now))
+	}
+	return l, nil
+}
+
+// fail counts the attempt against every throttle, and logs any lockout it
+// causes. It returns how long the attempt should have waited if that is
+// more than zero, and then counts nothing.
+func (a *loginAttempt) fail(ctx context.Context) (time.Duration, error) {
+	l, err := a.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer l.tx.Rollback()
+	if l.wait > 0 {
+		return l.wait, nil
+	}
+	for _, t := range a.throttles {
+		state, locked := t.policy.Fail(toLimitState(l.rows[t.key()]), l.now)
+		lockedUntil := sql.NullTime{Time: state.LockedUntil, Valid: !state.LockedUntil.IsZero()}
+		if err = l.q.UpdateLoginThrottle(ctx, database.UpdateLoginThrottleParams{
+			ThrottleKey:  t.key(),
+			Failures:     int32(state.Failures),
+			LastFailedAt: state.LastFailedAt,
+			LockedUntil:  lockedUntil,
+		}); err != nil {
+			return 0, err
+		}
+		if locked {
+			if err = l.q.RecordLoginLockout(ctx, database.RecordLoginLockoutParams{
+				Kind:        t.kind,
+				Subject:     t.subject,
+				IpAddress:   a.ip,
+				LockedUntil: state.LockedUntil,
+			}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err = l.tx.Commit(); err != nil {
+		return 0, err
+	}
+	// Throttles nobody has tripped in a while only take up space. Deleting
+	// them after the commit means this never waits on another attempt's
+	// rows while holding ours.
+	return 0, a.cfg.db.DeleteStaleLoginThrottles(ctx, l.now.Add(-max(emailLoginPolicy.Window, ipLoginPolicy.Window)))
+}
+
+// check returns how long the attempt should have waited, for a correct
+// password that isn't the whole login yet and so doesn't clear anything.
+func (a *loginAttempt) check(ctx context.Context) (time.Duration, error) {
+	l, err := a.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer l.tx.Rollback()
+	return l.wait, nil
+}
+
+// succeed forgets the failures against the email now that its owner has
+// logged in, unless the attempt should have waited, in which case it
+// returns the wait. The IP's failures stay, or an attacker could reset
+// their own count by logging into an account of theirs now and then.
+func (a *loginAttempt) succeed(ctx context.Context) (time.Duration, error) {
+	l, err := a.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer l.tx.Rollback()
+	if l.wait > 0 {
+		return l.wait, nil
+	}
+	for _, t := range a.throttles {
+		if t.kind != "email" {
+			continue
+		}
+		if err = l.q.DeleteLoginThrottle(ctx, t.key()); err != nil {
+			return 0, err
+		}
+	}
+	return 0, l.tx.Commit()
+}
+
+// countAttempt counts one go at something throttled like logins, but where
+// every go counts and not just the failures, such as asking for a password
+// reset email. It returns how long the client has to wait if it may not go
+// ahead now.
+func (cfg *apiConfig) countAttempt(ctx context.Context, throttles []loginThrottle, ip string) (time.Duration, error) {
+	attempt, wait, err := cfg.startLoginAttempt(ctx, throttles, ip)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	return attempt.fail(ctx)
+}
+
+// failLogin answers a login with a wrong email or password. Both get the
+// same response, and the same throttling.
+func failLogin(w http.ResponseWriter, r *http.Request, attempt *loginAttempt, err error) {
+	wait, failErr := attempt.fail(r.Context())
+	if failErr != nil {
+		respondError(w, http.StatusInternalServerError, "Error recording login attempt", failErr)
+		return
+	}
+	if wait > 0 {
+		respondTooManyLoginAttempts(w, wait)
+		return
+	}
+	respondError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+}
+
+func respondTooManyLoginAttempts(w http.ResponseWriter, wait time.Duration) {
+	respondTooManyRequests(w, wait, "Too many failed login attempts, try again later")
+}
+
+func respondTooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	respondError(w, http.StatusTooManyRequests, msg, nil)
+}
+
+func toLimitState(row database.LoginThrottle) loginlimit.State {
+	state := loginlimit.State{
+		Failures:     int(row.Failures),
+		LastFailedAt: row.LastFailedAt,
+	}
+	if row.LockedUntil.Valid {
+		state.LockedUntil = row.LockedUntil.Time
+	}
+	return state
+}
+
+func (cfg *apiConfig) handlerGetLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	limit, err := parsePageLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	rows, err := cfg.db.GetLoginLockouts(r.Context(), limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving lockouts", err)
+		return
+	}
+	lockouts := make([]LoginLockout, 0, len(rows))
+	for _, row := range rows {
+		lockouts = append(lockouts, LoginLockout{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			Kind:        row.Kind,
+			Subject:     row.Subject,
+			IPAddress:   row.IpAddress,
+			LockedUntil: row.LockedUntil,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, lockouts)
+}
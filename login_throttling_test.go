@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestLoginBurstThrottled(t *testing.T) {
+	cfg := testConfig(t)
+	credentials := map[string]string{"email": testEmail, "password": testPassword}
+	if rec := serveJSON(t, http.HandlerFunc(cfg.handlerAddUser), http.MethodPost, "", credentials, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("signing up: %d %s", rec.Code, rec.Body)
+	}
+	// Guesses sent at once get no more answers than guesses sent one
+	// after the other would.
+	guess := map[string]string{"email": testEmail, "password": "wrong"}
+	codes := make(chan int, 20)
+	var wg sync.WaitGroup
+	for range cap(codes) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serveJSON(t, http.HandlerFunc(cfg.handlerLogin), http.MethodPost, "", guess, nil).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	answered := 0
+	for code := range codes {
+		switch code {
+		case http.StatusUnauthorized:
+			answered++
+		case http.StatusTooManyRequests:
+		default:
+			t.Fatalf("expected 401 or 429, got %d", code)
+		}
+	}
+	if answered > emailLoginPolicy.FreeAttempts+1 {
+		t.Fatalf("expected at most %d guesses to be answered, got %d", emailLoginPolicy.FreeAttempts+1, answered)
+	}
+}
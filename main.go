@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +12,11 @@ import (
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/snowkittyselene/chirpy/internal/auth"
 	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/mail"
+	"github.com/snowkittyselene/chirpy/internal/passwordpolicy"
+	"github.com/snowkittyselene/chirpy/internal/storage"
 )
 
 const port = "8080"
@@ -18,10 +24,19 @@ const rootFilePath = "."
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	conn           *sql.DB
 	db             *database.Queries
 	platform       string
-	secret         string
+	keys           *auth.KeySet
 	apiKey         string
+	revocations    *tokenRevocations
+	mailer         mail.Sender
+	passwordPolicy *passwordpolicy.Policy
+	passwords      *auth.PasswordHasher
+	storage        storage.Storage
+	// dummyHash is checked against when a login's email doesn't belong
+	// to an account, so those logins take as long as wrong passwords.
+	dummyHash string
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -39,47 +54,164 @@ func (cfg *apiConfig) handlerCountRequests(w http.ResponseWriter, _ *http.Reques
 }
 
 func main() {
+	makeAdminEmail := flag.String("make-admin", "", "promote the user with this email to admin and exit")
+	generateKey := flag.String("generate-key", "", "write a new `ed25519|rsa` signing key to JWT_KEYS_DIR and exit")
+	flag.Parse()
 	godotenv.Load()
 	dbUrl := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
 	secret := os.Getenv("TOKEN_SECRET")
 	apiKey := os.Getenv("POLKA_KEY")
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	signingKeyID := os.Getenv("JWT_SIGNING_KEY_ID")
+	if *generateKey != "" {
+		if keysDir == "" {
+			log.Fatal("JWT_KEYS_DIR must be set to generate a key")
+		}
+		kid, err := auth.GenerateKey(keysDir, *generateKey)
+		if err != nil {
+			log.Fatalf("error generating key: %s", err)
+		}
+		log.Printf("Generated key %s", kid)
+		return
+	}
 	db, err := sql.Open("postgres", dbUrl)
 	if err != nil {
 		log.Fatalf("error opening database: %s", err)
 	}
 	dbQueries := database.New(db)
+	if *makeAdminEmail != "" {
+		if err := makeAdmin(context.Background(), dbQueries, *makeAdminEmail); err != nil {
+			log.Fatalf("error making %s an admin: %s", *makeAdminEmail, err)
+		}
+		log.Printf("%s is now an admin", *makeAdminEmail)
+		return
+	}
+	keys, err := loadKeys(keysDir, signingKeyID, secret)
+	if err != nil {
+		log.Fatalf("error loading signing keys: %s", err)
+	}
+	passwordPolicy, err := loadPasswordPolicy()
+	if err != nil {
+		log.Fatalf("error loading password policy: %s", err)
+	}
+	passwords, err := loadPasswordHasher()
+	if err != nil {
+		log.Fatalf("error configuring password hashing: %s", err)
+	}
+	dummyHash, err := passwords.Hash("not anyone's password")
+	if err != nil {
+		log.Fatalf("error configuring password hashing: %s", err)
+	}
+	mediaStorage, err := loadStorage()
+	if err != nil {
+		log.Fatalf("error configuring media storage: %s", err)
+	}
 
-	mux := http.NewServeMux()
-	handler := http.StripPrefix("/app", http.FileServer(http.Dir(rootFilePath)))
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
+		conn:           db,
 		db:             dbQueries,
 		platform:       platform,
-		secret:         secret,
+		keys:           keys,
 		apiKey:         apiKey,
+		revocations:    newTokenRevocations(dbQueries),
+		mailer:         loadMailer(),
+		passwordPolicy: passwordPolicy,
+		passwords:      passwords,
+		dummyHash:      dummyHash,
+		storage:        mediaStorage,
 	}
-	mux.Handle("/app/", apiCfg.middlewareMetricsInc(handler))
-
-	mux.HandleFunc("GET /api/healthz", handlerReady)
-	mux.HandleFunc("POST /api/users", apiCfg.handlerAddUser)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerAddChirp)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetAllChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirpByID)
-	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgradeUser)
-
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerCountRequests)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
 
 	srv := http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: apiCfg.routes(),
 	}
 	log.Printf("Serving files from %s on port: %s", rootFilePath, port)
 	log.Fatal(srv.ListenAndServe())
 }
+
+// routes registers every endpoint. ServeMux panics on patterns that
+// conflict, so a bad route shows up as soon as this runs.
+func (cfg *apiConfig) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	handler := http.StripPrefix("/app", http.FileServer(http.Dir(rootFilePath)))
+	mux.Handle("/app/", cfg.middlewareMetricsInc(handler))
+
+	authMiddleware := auth.NewMiddleware(cfg.keys, cfg.revocations)
+	requireUser := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.Required(handler)
+	}
+	optionalUser := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.Optional(handler)
+	}
+	requireVerifiedUser := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.Required(cfg.middlewareVerifiedEmail(handler))
+	}
+
+	mux.HandleFunc("GET /api/healthz", handlerReady)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.handlerJWKS)
+	mux.HandleFunc("POST /api/users", cfg.handlerAddUser)
+	mux.Handle("POST /api/chirps", requireVerifiedUser(cfg.handlerAddChirp))
+	mux.Handle("GET /api/chirps", optionalUser(cfg.handlerGetAllChirps))
+	mux.Handle("GET /api/chirps/{chirpID}", optionalUser(cfg.handlerGetChirpByID))
+	mux.Handle("GET /api/chirps/search", optionalUser(cfg.handlerSearchChirps))
+	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/2fa", cfg.handlerLoginTwoFactor)
+	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+	mux.Handle("POST /api/logout", requireUser(cfg.handlerLogout))
+	mux.HandleFunc("POST /api/password-reset/request", cfg.handlerRequestPasswordReset)
+	mux.HandleFunc("POST /api/password-reset/confirm", cfg.handlerConfirmPasswordReset)
+	// PUT predates PATCH and is kept as an alias of it. Like PATCH it now
+	// wants current_password to change the email or password, which breaks
+	// clients that don't send it; see the README.
+	mux.Handle("PUT /api/users", requireUser(cfg.handlerUpdateUser))
+	mux.Handle("PATCH /api/users", requireUser(cfg.handlerUpdateUser))
+	mux.HandleFunc("POST /api/users/verify", cfg.handlerVerifyEmail)
+	mux.Handle("POST /api/users/verify/resend", requireUser(cfg.handlerResendVerification))
+	mux.Handle("POST /api/users/2fa", requireUser(cfg.handlerStartTwoFactor))
+	mux.Handle("POST /api/users/2fa/confirm", requireUser(cfg.handlerConfirmTwoFactor))
+	mux.Handle("DELETE /api/users/2fa", requireUser(cfg.handlerDisableTwoFactor))
+	mux.Handle("GET /api/sessions", requireUser(cfg.handlerGetSessions))
+	mux.Handle("DELETE /api/sessions/{sessionID}", requireUser(cfg.handlerRevokeSession))
+	mux.Handle("POST /api/sessions/revoke-all", requireUser(cfg.handlerRevokeAllSessions))
+	mux.Handle("DELETE /api/chirps/{chirpID}", requireUser(cfg.handlerDeleteChirp))
+	mux.Handle("PUT /api/chirps/{chirpID}", requireVerifiedUser(cfg.handlerUpdateChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.handlerGetChirpRevisions)
+	mux.Handle("GET /api/chirps/{chirpID}/replies", optionalUser(cfg.handlerGetChirpReplies))
+	mux.Handle("POST /api/chirps/{chirpID}/likes", requireUser(cfg.handlerLikeChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}/likes", requireUser(cfg.handlerUnlikeChirp))
+	mux.Handle("POST /api/chirps/{chirpID}/rechirp", requireVerifiedUser(cfg.handlerRechirp))
+	mux.HandleFunc("GET /api/hashtags/trending", cfg.handlerGetTrendingHashtags)
+	mux.Handle("GET /api/hashtags/{tag}/chirps", optionalUser(cfg.handlerGetChirpsByHashtag))
+	mux.HandleFunc("GET /api/users/{userID}", cfg.handlerGetUserProfile)
+	mux.HandleFunc("GET /api/handles/{handle}", cfg.handlerGetUserProfileByHandle)
+	mux.Handle("POST /api/users/{userID}/follow", requireUser(cfg.handlerFollowUser))
+	mux.Handle("DELETE /api/users/{userID}/follow", requireUser(cfg.handlerUnfollowUser))
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.handlerGetFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.handlerGetFollowing)
+	mux.Handle("GET /api/feed", requireUser(cfg.handlerGetFeed))
+	mux.Handle("POST /api/media", requireVerifiedUser(cfg.handlerUploadMedia))
+	mux.HandleFunc("GET /media/{mediaID}", cfg.handlerGetMedia)
+	mux.HandleFunc("GET /media/{mediaID}/thumbnail", cfg.handlerGetMediaThumbnail)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerUpgradeUser)
+
+	// Everything under /admin needs at least a moderator; most of it needs
+	// an admin.
+	adminMux := http.NewServeMux()
+	requireAdmin := func(handler http.HandlerFunc) http.Handler {
+		return auth.RequireRole(auth.RoleAdmin, handler)
+	}
+	adminMux.Handle("GET /admin/metrics", requireAdmin(cfg.handlerCountRequests))
+	adminMux.Handle("POST /admin/reset", requireAdmin(cfg.handlerReset))
+	adminMux.Handle("GET /admin/badwords", requireAdmin(cfg.handlerGetBadWords))
+	adminMux.Handle("POST /admin/badwords", requireAdmin(cfg.handlerSetBadWord))
+	adminMux.Handle("DELETE /admin/badwords/{word}", requireAdmin(cfg.handlerDeleteBadWord))
+	adminMux.Handle("PUT /admin/users/{userID}/role", requireAdmin(cfg.handlerSetUserRole))
+	adminMux.Handle("POST /admin/users/{userID}/sign-out", requireAdmin(cfg.handlerSignOutUser))
+	adminMux.Handle("GET /admin/lockouts", requireAdmin(cfg.handlerGetLoginLockouts))
+	adminMux.HandleFunc("GET /admin/flagged", cfg.handlerGetFlaggedChirps)
+	mux.Handle("/admin/", authMiddleware.Required(auth.RequireRole(auth.RoleModerator, adminMux)))
+	return mux
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/mail"
+	"github.com/snowkittyselene/chirpy/internal/storage"
+)
+
+// testConfig returns an apiConfig backed by the Postgres database in
+// TEST_DB_URL, skipping the test when it isn't set. The database is wiped
+// and migrated from sql/schema first, so don't point it at anything you
+// want to keep. Tests using it can't run in parallel.
+func testConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err = db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public;"); err != nil {
+		t.Fatalf("wiping database: %v", err)
+	}
+	migrations, err := filepath.Glob("sql/schema/*.sql")
+	if err != nil {
+		t.Fatalf("finding migrations: %v", err)
+	}
+	for _, path := range migrations {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		if _, err = db.Exec(up); err != nil {
+			t.Fatalf("running %s: %v", path, err)
+		}
+	}
+	// The cheapest hashing there is, since the tests hash a lot.
+	passwords := &auth.PasswordHasher{Algorithm: auth.Bcrypt, BcryptCost: 4}
+	policy, err := loadPasswordPolicy()
+	if err != nil {
+		t.Fatalf("loading password policy: %v", err)
+	}
+	dbQueries := database.New(db)
+	cfg := &apiConfig{
+		conn:           db,
+		db:             dbQueries,
+		keys:           auth.NewHMACKeySet("test secret"),
+		revocations:    newTokenRevocations(dbQueries),
+		mailer:         mail.LogSender{},
+		passwordPolicy: policy,
+		passwords:      passwords,
+		storage:        storage.NewLocal(t.TempDir()),
+	}
+	cfg.dummyHash, err = passwords.Hash("not anyone's password")
+	if err != nil {
+		t.Fatalf("hashing: %v", err)
+	}
+	return cfg
+}
+
+// serveJSON sends body as JSON to handler, with token as the bearer token
+// unless it is empty, and decodes the response into out if it isn't nil.
+func serveJSON(t *testing.T, handler http.Handler, method, token string, body, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("encoding request: %v", err)
+	}
+	req := httptest.NewRequest(method, "/", bytes.NewReader(data))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if out != nil && rec.Code < 300 {
+		if err = json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+	}
+	return rec
+}
+
+func TestRoutes(t *testing.T) {
+	// Conflicting patterns make routes panic.
+	mux := (&apiConfig{}).routes()
+	for request, want := range map[string]string{
+		"GET /api/handles/followers":       "GET /api/handles/{handle}",
+		"GET /api/users/someone":           "GET /api/users/{userID}",
+		"GET /api/users/someone/followers": "GET /api/users/{userID}/followers",
+		"GET /api/chirps/search":           "GET /api/chirps/search",
+		"GET /media/something/thumbnail":   "GET /media/{mediaID}/thumbnail",
+		"PATCH /api/users":                 "PATCH /api/users",
+	} {
+		method, path, _ := strings.Cut(request, " ")
+		if _, pattern := mux.Handler(httptest.NewRequest(method, path, nil)); pattern != want {
+			t.Errorf("%s matched %q, want %q", request, pattern, want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/loginlimit"
+	"github.com/snowkittyselene/chirpy/internal/media"
+	"github.com/snowkittyselene/chirpy/internal/storage"
+)
+
+const (
+	maxMediaBytes  = 10 << 20
+	maxMediaPixels = 25_000_000
+	thumbnailSize  = 320
+	maxChirpMedia  = 4
+	// maxMediaProcessing is how many uploads are decoded at once. A
+	// decoded image can take around a hundred megabytes.
+	maxMediaProcessing = 4
+	// unattachedMediaLifetime is how long an upload is kept while it
+	// isn't attached to a chirp or used as an avatar.
+	unattachedMediaLifetime = 24 * time.Hour
+)
+
+// mediaProcessing holds a slot for every upload being decoded.
+var mediaProcessing = make(chan struct{}, maxMediaProcessing)
+
+// Uploads are limited per user. Every upload counts, whether or not it
+// works out, and the limit never locks anyone out.
+var uploadPolicy = loginlimit.Policy{
+	FreeAttempts: 30,
+	BaseDelay:    time.Minute,
+	MaxDelay:     15 * time.Minute,
+	Window:       time.Hour,
+}
+
+func uploadThrottles(userID uuid.UUID) []loginThrottle {
+	return []loginThrottle{
+		{kind: "upload_user", subject: userID.String(), policy: uploadPolicy},
+	}
+}
+
+type Media struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	URL         string    `json:"url"`
+	// ThumbnailURL is null for media stored without a thumbnail.
+	ThumbnailURL *string `json:"thumbnail_url"`
+}
+
+func toMedia(m database.Medium) Media {
+	response := Media{
+		ID:          m.ID,
+		CreatedAt:   m.CreatedAt,
+		ContentType: m.ContentType,
+		Size:        m.SizeBytes,
+		Width:       int(m.Width),
+		Height:      int(m.Height),
+		URL:         "/media/" + m.ID.String(),
+	}
+	if m.ThumbnailContentType.Valid {
+		thumbnailURL := response.URL + "/thumbnail"
+		response.ThumbnailURL = &thumbnailURL
+	}
+	return response
+}
+
+func mediaKey(id uuid.UUID) string {
+	return "media/" + id.String()
+}
+
+func thumbnailKey(id uuid.UUID) string {
+	return "media/" + id.String() + "-thumbnail"
+}
+
+// loadStorage picks where uploads are kept: in the S3 bucket S3_BUCKET
+// when it is set, otherwise in MEDIA_DIR. With neither there is nowhere
+// safe to keep them, so it returns nil and media is turned off. S3_ENDPOINT
+// points at an S3-compatible server other than AWS, which is addressed
+// path-style.
+func loadStorage() (storage.Storage, error) {
+	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
+		endpoint := os.Getenv("S3_ENDPOINT")
+		return storage.NewS3(storage.S3Config{
+			Endpoint:        endpoint,
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          bucket,
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       endpoint != "",
+		})
+	}
+	if dir := os.Getenv("MEDIA_DIR"); dir != "" {
+		return storage.NewLocal(dir), nil
+	}
+	log.Print("Neither S3_BUCKET nor MEDIA_DIR is set, media uploads are turned off")
+	return nil, nil
+}
+
+// checkStorage responds and returns false if media is turned off.
+func (cfg *apiConfig) checkStorage(w http.ResponseWriter) bool {
+	if cfg.storage == nil {
+		respondError(w, http.StatusServiceUnavailable, "Media uploads are not set up on this server", nil)
+		return false
+	}
+	return true
+}
+
+// handlerUploadMedia takes an image in the "file" field of a multipart
+// form. What gets stored is the image as media.Process leaves it, not as
+// it was sent.
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	if !cfg.checkStorage(w) {
+		return
+	}
+	userID, _ := auth.UserIDFromContext(r.Context())
+	wait, err := cfg.countAttempt(r.Context(), uploadThrottles(userID), clientIP(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking uploads", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyRequests(w, wait, "Too many uploads, try again later")
+		return
+	}
+	if err = cfg.deleteUnattachedMedia(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error cleaning up uploads", err)
+		return
+	}
+	// Leave room for the multipart framing around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaBytes+1<<20)
+	data, err := readUpload(r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || errors.Is(err, errUploadTooLarge) {
+		respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Files can be at most %d MiB", maxMediaBytes>>20), err)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	img, err := processMedia(r.Context(), data)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		respondError(w, http.StatusUnsupportedMediaType, err.Error(), err)
+		return
+	case errors.Is(err, media.ErrTooManyPixels):
+		respondError(w, http.StatusRequestEntityTooLarge, err.Error(), err)
+		return
+	case errors.Is(err, media.ErrInvalidImage):
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, "Error processing image", err)
+		return
+	}
+	id := uuid.New()
+	if err = cfg.storage.Put(r.Context(), mediaKey(id), img.ContentType, img.Data); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error storing image", err)
+		return
+	}
+	thumbnailType := sql.NullString{}
+	if img.Thumbnail != nil {
+		if err = cfg.storage.Put(r.Context(), thumbnailKey(id), img.ThumbnailContentType, img.Thumbnail); err != nil {
+			cfg.deleteMediaFiles(r.Context(), id)
+			respondError(w, http.StatusInternalServerError, "Error storing thumbnail", err)
+			return
+		}
+		thumbnailType = sql.NullString{String: img.ThumbnailContentType, Valid: true}
+	}
+	m, err := cfg.db.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:                   id,
+		UserID:               userID,
+		ContentType:          img.ContentType,
+		SizeBytes:            int64(len(img.Data)),
+		Width:                int32(img.Width),
+		Height:               int32(img.Height),
+		ThumbnailContentType: thumbnailType,
+	})
+	if err != nil {
+		cfg.deleteMediaFiles(r.Context(), id)
+		respondError(w, http.StatusInternalServerError, "Error saving media", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, toMedia(m))
+}
+
+var errUploadTooLarge = errors.New("upload is too large")
+
+// processMedia runs media.Process once there is a slot free for it, so a
+// burst of uploads can't decode more images at once than memory allows.
+func processMedia(ctx context.Context, data []byte) (*media.Image, error) {
+	select {
+	case mediaProcessing <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-mediaProcessing }()
+	return media.Process(data, media.Options{MaxPixels: maxMediaPixels, ThumbnailSize: thumbnailSize})
+}
+
+// readUpload reads the "file" part of a multipart request, without
+// spooling anything to disk.
+func readUpload(r *http.Request) ([]byte, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("Request must be multipart/form-data")
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("Request has no file field")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, maxMediaBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxMediaBytes {
+			return nil, errUploadTooLarge
+		}
+		return data, nil
+	}
+}
+
+// deleteUnattachedMedia deletes a batch of uploads that were never
+// attached to anything, or no longer are, along with their files.
+//
+// Anything attaching an upload locks it first, for share, and this skips
+// uploads that are locked. The ones it does lock are checked again before
+// being deleted, since an upload can have been attached and its lock let
+// go between the two.
+func (cfg *apiConfig) deleteUnattachedMedia(ctx context.Context) error {
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	ids, err := qtx.LockUnattachedMedia(ctx, time.Now().UTC().Add(-unattachedMediaLifetime))
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	if ids, err = qtx.DeleteUnattachedMedia(ctx, ids); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		cfg.deleteMediaFiles(ctx, id)
+	}
+	return nil
+}
+
+// deleteMediaFiles deletes the files of an upload, logging rather than
+// returning errors since there is nothing more to be done about them.
+func (cfg *apiConfig) deleteMediaFiles(ctx context.Context, id uuid.UUID) {
+	for _, key := range []string{mediaKey(id), thumbnailKey(id)} {
+		if err := cfg.storage.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting %s: %s", key, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerGetMedia(w http.ResponseWriter, r *http.Request) {
+	if !cfg.checkStorage(w) {
+		return
+	}
+	m, ok := cfg.findMedia(w, r)
+	if !ok {
+		return
+	}
+	cfg.serveMediaFile(w, r, mediaKey(m.ID), m.ContentType, m.CreatedAt)
+}
+
+func (cfg *apiConfig) handlerGetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	if !cfg.checkStorage(w) {
+		return
+	}
+	m, ok := cfg.findMedia(w, r)
+	if !ok {
+		return
+	}
+	if !m.ThumbnailContentType.Valid {
+		respondError(w, http.StatusNotFound, "Media has no thumbnail", nil)
+		return
+	}
+	cfg.serveMediaFile(w, r, thumbnailKey(m.ID), m.ThumbnailContentType.String, m.CreatedAt)
+}
+
+func (cfg *apiConfig) findMedia(w http.ResponseWriter, r *http.Request) (database.Medium, bool) {
+	id, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return database.Medium{}, false
+	}
+	m, err := cfg.db.GetMedia(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "Media not found", err)
+		return database.Medium{}, false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving media", err)
+		return database.Medium{}, false
+	}
+	return m, true
+}
+
+// serveMediaFile serves a stored file the way the file server would,
+// including ranges when the storage allows them. Files never change once
+// uploaded, so they can be cached for good.
+func (cfg *apiConfig) serveMediaFile(w http.ResponseWriter, r *http.Request, key, contentType string, modTime time.Time) {
+	obj, err := cfg.storage.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(w, http.StatusNotFound, "Media not found", err)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving media", err)
+		return
+	}
+	defer obj.Body.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	if body, ok := obj.Body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", modTime, body)
+		return
+	}
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(obj.Size))
+	}
+	if _, err = io.Copy(w, obj.Body); err != nil {
+		log.Printf("Error serving %s: %s", key, err)
+	}
+}
+
+// checkChirpMedia responds and returns false unless ids are at most
+// maxChirpMedia different uploads of the user's. It locks them through q,
+// which has to be the transaction attaching them, so they can't be cleaned
+// up as unattached before it commits.
+func checkChirpMedia(w http.ResponseWriter, r *http.Request, q *database.Queries, userID uuid.UUID, ids []uuid.UUID) bool {
+	if len(ids) > maxChirpMedia {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("A chirp can have at most %d media", maxChirpMedia), nil)
+		return false
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			respondError(w, http.StatusBadRequest, "The same media is attached twice", nil)
+			return false
+		}
+		seen[id] = true
+	}
+	if len(ids) == 0 {
+		return true
+	}
+	owned, err := q.GetUserMediaForShare(r.Context(), database.GetUserMediaForShareParams{UserID: userID, Ids: ids})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving media", err)
+		return false
+	}
+	if len(owned) != len(ids) {
+		respondError(w, http.StatusBadRequest, "Media not found", nil)
+		return false
+	}
+	return true
+}
+
+// getChirpMedia looks up the media attached to a list of chirps with one
+// query, in the order they were attached.
+func (cfg *apiConfig) getChirpMedia(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]Media, error) {
+	rows, err := cfg.db.GetMediaForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	attached := map[uuid.UUID][]Media{}
+	for _, row := range rows {
+		attached[row.ChirpID] = append(attached[row.ChirpID], toMedia(database.Medium{
+			ID:                   row.ID,
+			CreatedAt:            row.CreatedAt,
+			UserID:               row.UserID,
+			ContentType:          row.ContentType,
+			SizeBytes:            row.SizeBytes,
+			Width:                row.Width,
+			Height:               row.Height,
+			ThumbnailContentType: row.ThumbnailContentType,
+		}))
+	}
+	return attached, nil
+}
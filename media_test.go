@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/storage"
+)
+
+func TestMediaWithoutStorage(t *testing.T) {
+	mux := (&apiConfig{}).routes()
+	for _, path := range []string{"/media/" + uuid.NewString(), "/media/" + uuid.NewString() + "/thumbnail"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s gave %d, want 503", path, rec.Code)
+		}
+	}
+}
+
+func TestUploadsThrottled(t *testing.T) {
+	cfg := testConfig(t)
+	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{Email: testEmail, HashedPassword: "unused"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	token, err := auth.MakeJWT(user.ID, auth.RoleUser, cfg.keys, time.Minute)
+	if err != nil {
+		t.Fatalf("making token: %v", err)
+	}
+	handler := auth.NewMiddleware(cfg.keys, cfg.revocations).Required(http.HandlerFunc(cfg.handlerUploadMedia))
+	// Uploads that aren't even multipart still count.
+	for i := 0; i <= uploadPolicy.FreeAttempts; i++ {
+		if rec := serveJSON(t, handler, http.MethodPost, token, nil, nil); rec.Code != http.StatusBadRequest {
+			t.Fatalf("upload %d: expected 400, got %d", i+1, rec.Code)
+		}
+	}
+	rec := serveJSON(t, handler, http.MethodPost, token, nil, nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", rec.Code)
+	}
+}
+
+func TestDeleteUnattachedMedia(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+	user, err := cfg.db.CreateUser(ctx, database.CreateUserParams{Email: testEmail, HashedPassword: "unused"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	upload := func() uuid.UUID {
+		id := uuid.New()
+		if err := cfg.storage.Put(ctx, mediaKey(id), "image/png", []byte("png")); err != nil {
+			t.Fatalf("storing file: %v", err)
+		}
+		if _, err := cfg.db.CreateMedia(ctx, database.CreateMediaParams{
+			ID:          id,
+			UserID:      user.ID,
+			ContentType: "image/png",
+			SizeBytes:   3,
+			Width:       1,
+			Height:      1,
+		}); err != nil {
+			t.Fatalf("creating media: %v", err)
+		}
+		return id
+	}
+	avatar, unattached := upload(), upload()
+	if _, err = cfg.db.UpdateUser(ctx, database.UpdateUserParams{
+		ID:            user.ID,
+		AvatarUrl:     sql.NullString{Valid: true},
+		AvatarMediaID: uuid.NullUUID{UUID: avatar, Valid: true},
+	}); err != nil {
+		t.Fatalf("setting avatar: %v", err)
+	}
+	if _, err = cfg.conn.Exec("UPDATE media SET created_at = created_at - $1 * INTERVAL '1 second'", (unattachedMediaLifetime + time.Hour).Seconds()); err != nil {
+		t.Fatalf("backdating media: %v", err)
+	}
+	recent := upload()
+	if err = cfg.deleteUnattachedMedia(ctx); err != nil {
+		t.Fatalf("deleting unattached media: %v", err)
+	}
+	for _, id := range []uuid.UUID{avatar, recent} {
+		if _, err = cfg.db.GetMedia(ctx, id); err != nil {
+			t.Fatalf("expected media %s to be kept, got %v", id, err)
+		}
+	}
+	if _, err = cfg.db.GetMedia(ctx, unattached); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected unattached media to be deleted, got %v", err)
+	}
+	if _, err = cfg.storage.Get(ctx, mediaKey(unattached)); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected unattached media's file to be deleted, got %v", err)
+	}
+}
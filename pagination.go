@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+const defaultPageSize = 20
+const maxPageSize = 100
+
+// chirpCursor marks the last chirp of a page. Listings are ordered by
+// (created_at, id), so the pair is enough to resume from exactly that row.
+type chirpCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+type pageRequest struct {
+	cursor     *chirpCursor
+	limit      int32
+	descending bool
+}
+
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (chirpCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return chirpCursor{}, err
+	}
+	createdAtStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return chirpCursor{}, fmt.Errorf("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return chirpCursor{}, err
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return chirpCursor{}, err
+	}
+	return chirpCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// parsePageRequest reads the `cursor`, `limit` and `sort` query parameters.
+// The limit defaults to defaultPageSize and is capped at maxPageSize, and the
+// sort order defaults to oldest first.
+func parsePageRequest(r *http.Request) (pageRequest, error) {
+	query := r.URL.Query()
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		return pageRequest{}, err
+	}
+	page := pageRequest{limit: limit}
+	switch query.Get("sort") {
+	case "", "asc":
+	case "desc":
+		page.descending = true
+	default:
+		return pageRequest{}, fmt.Errorf("sort must be either asc or desc")
+	}
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		cursor, err := decodeCursor(cursorStr)
+		if err != nil {
+			return pageRequest{}, fmt.Errorf("invalid cursor")
+		}
+		page.cursor = &cursor
+	}
+	return page, nil
+}
+
+func parsePageLimit(limitStr string) (int32, error) {
+	if limitStr == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive integer")
+	}
+	return int32(min(limit, maxPageSize)), nil
+}
+
+// queryLimit asks the database for one row more than the page size so we
+// can tell whether there is a next page without a separate count query.
+func (p pageRequest) queryLimit() int32 {
+	return p.limit + 1
+}
+
+func (p pageRequest) cursorCreatedAt() sql.NullTime {
+	if p.cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.cursor.CreatedAt, Valid: true}
+}
+
+func (p pageRequest) cursorID() uuid.NullUUID {
+	if p.cursor == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.cursor.ID, Valid: true}
+}
+
+// trim drops the extra row fetched because of queryLimit and, when there was
+// one, links to the page that follows.
+func (p pageRequest) trim(w http.ResponseWriter, r *http.Request, chirps []database.Chirp) []database.Chirp {
+	if len(chirps) <= int(p.limit) {
+		return chirps
+	}
+	chirps = chirps[:p.limit]
+	last := chirps[len(chirps)-1]
+	setNextPageLink(w, r, encodeCursor(last.CreatedAt, last.ID))
+	return chirps
+}
+
+// setNextPageLink adds a Link header pointing at the page after the given
+// cursor, keeping every other query parameter of the original request.
+func setNextPageLink(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", nextCursor)
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+// loadPasswordHasher configures how new passwords are hashed from
+// PASSWORD_HASH_ALGORITHM (argon2id or bcrypt), ARGON2_TIME,
+// ARGON2_MEMORY_KIB, ARGON2_THREADS and BCRYPT_COST. Anything unset keeps
+// its default. Stored hashes made any other way still work, and are
+// upgraded as their owners log in.
+func loadPasswordHasher() (*auth.PasswordHasher, error) {
+	h := auth.NewPasswordHasher()
+	if alg := os.Getenv("PASSWORD_HASH_ALGORITHM"); alg != "" {
+		h.Algorithm = alg
+	}
+	for _, setting := range []struct {
+		env string
+		set func(n uint64)
+	}{
+		{"ARGON2_TIME", func(n uint64) { h.Argon2.Time = uint32(n) }},
+		{"ARGON2_MEMORY_KIB", func(n uint64) { h.Argon2.Memory = uint32(n) }},
+		{"ARGON2_THREADS", func(n uint64) { h.Argon2.Threads = uint8(n) }},
+		{"BCRYPT_COST", func(n uint64) { h.BcryptCost = int(n) }},
+	} {
+		s := os.Getenv(setting.env)
+		if s == "" {
+			continue
+		}
+		bits := 32
+		if setting.env == "ARGON2_THREADS" {
+			bits = 8
+		}
+		n, err := strconv.ParseUint(s, 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a positive integer", setting.env)
+		}
+		setting.set(n)
+	}
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// rehashPassword replaces a stored hash made with old settings. It only
+// does so if the hash is still the one that was checked, so a password
+// changed in the meantime isn't reverted.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password, oldHash string) error {
+	newHash, err := cfg.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+	return cfg.db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+		NewHash: newHash,
+		ID:      userID,
+		OldHash: oldHash,
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/snowkittyselene/chirpy/internal/passwordpolicy"
+)
+
+const (
+	defaultPasswordMinLength   = 8
+	defaultBannedPasswordsFile = "banned_passwords.txt"
+)
+
+// loadPasswordPolicy builds the policy from PASSWORD_MIN_LENGTH and the
+// list of banned passwords in PASSWORD_BANNED_LIST, falling back to the
+// short list that ships with Chirpy.
+func loadPasswordPolicy() (*passwordpolicy.Policy, error) {
+	minLength := defaultPasswordMinLength
+	if s := os.Getenv("PASSWORD_MIN_LENGTH"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must be a positive integer")
+		}
+		minLength = n
+	}
+	policy := passwordpolicy.New(minLength)
+	path := os.Getenv("PASSWORD_BANNED_LIST")
+	if path == "" {
+		err := policy.LoadBannedFile(defaultBannedPasswordsFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Printf("%s not found, not banning any passwords", defaultBannedPasswordsFile)
+			return policy, nil
+		}
+		return policy, err
+	}
+	return policy, policy.LoadBannedFile(path)
+}
+
+// checkPassword responds with every rule password breaks and returns false,
+// or returns true if it is acceptable for the account with email.
+func (cfg *apiConfig) checkPassword(w http.ResponseWriter, password, email string) bool {
+	violations := cfg.passwordPolicy.Check(password, email)
+	if len(violations) == 0 {
+		return true
+	}
+	respondWithJSON(w, http.StatusBadRequest, struct {
+		Error      string                     `json:"error"`
+		Violations []passwordpolicy.Violation `json:"violations"`
+	}{
+		Error:      "Password does not meet the requirements",
+		Violations: violations,
+	})
+	return false
+}
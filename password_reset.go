@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/loginlimit"
+	"github.com/snowkittyselene/chirpy/internal/mail"
+)
+
+const (
+	passwordResetLifetime = time.Hour
+	// passwordResetSendTimeout bounds the background work of a reset
+	// request, which outlives the request itself.
+	passwordResetSendTimeout = 30 * time.Second
+)
+
+// Reset requests are limited per email address, so nobody's inbox can be
+// flooded, and per IP address, so one client can't flood many. Every
+// request counts, whether or not the email belongs to an account. These
+// never lock out: the delays are long enough.
+var (
+	emailPasswordResetPolicy = loginlimit.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
+	ipPasswordResetPolicy = loginlimit.Policy{
+		FreeAttempts: 10,
+		BaseDelay:    time.Minute,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+func passwordResetThrottles(email, ip string) []loginThrottle {
+	return []loginThrottle{
+		{kind: "reset_email", subject: strings.ToLower(strings.TrimSpace(email)), policy: emailPasswordResetPolicy},
+		{kind: "reset_ip", subject: ip, policy: ipPasswordResetPolicy},
+	}
+}
+
+// handlerRequestPasswordReset mails a reset code to the address if it
+// belongs to an account. The response is the same either way, and the
+// lookup and sending happen after responding so the timing doesn't give
+// the answer away either. Requests are throttled per email and IP whether
+// or not the email belongs to an account.
+func (cfg *apiConfig) handlerRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Email string `json:"email"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	wait, err := cfg.countAttempt(r.Context(), passwordResetThrottles(req.Email, clientIP(r)), clientIP(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking reset requests", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyRequests(w, wait, "Too many password reset requests, try again later")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), passwordResetSendTimeout)
+	go func() {
+		defer cancel()
+		if err := cfg.sendPasswordReset(ctx, req.Email); err != nil {
+			log.Printf("Error sending password reset: %s", err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		// Most likely there is no such account, which is nobody's business.
+		return nil
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	if err = cfg.db.DeletePasswordResetTokens(ctx, user.ID); err != nil {
+		return err
+	}
+	if err = cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetLifetime),
+	}); err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account. To choose a new one, use this code:\n\n%s\n\nThe code expires in %s and can only be used once. If you didn't ask for this, you can ignore this email.\n",
+			token, passwordResetLifetime),
+	})
+}
+
+// handlerConfirmPasswordReset sets a new password using a code from
+// handlerRequestPasswordReset, and signs the user out everywhere.
+func (cfg *apiConfig) handlerConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	userID, err := qtx.ConsumePasswordResetToken(r.Context(), auth.HashToken(req.Token))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Reset code is invalid or has expired", err)
+		return
+	}
+	user, err := qtx.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Reset code is invalid or has expired", err)
+		return
+	}
+	// Rejecting the password rolls back the transaction, so the code can
+	// be used again with a better one.
+	if !cfg.checkPassword(w, req.Password, user.Email) {
+		return
+	}
+	hashedPassword, err := cfg.passwords.Hash(req.Password)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Error hashing password", err)
+		return
+	}
+	if err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating password", err)
+		return
+	}
+	if err = qtx.DeletePasswordResetTokens(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating password", err)
+		return
+	}
+	if err = qtx.RevokeAllSessions(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating password", err)
+		return
+	}
+	if err = cfg.revocations.revokeUser(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking tokens", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPasswordResetRequestsThrottled(t *testing.T) {
+	cfg := testConfig(t)
+	handler := http.HandlerFunc(cfg.handlerRequestPasswordReset)
+	req := map[string]string{"email": "nobody@example.com"}
+	for i := 0; i <= emailPasswordResetPolicy.FreeAttempts; i++ {
+		if rec := serveJSON(t, handler, http.MethodPost, "", req, nil); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d: expected 202, got %d", i+1, rec.Code)
+		}
+	}
+	rec := serveJSON(t, handler, http.MethodPost, "", req, nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", rec.Code)
+	}
+}
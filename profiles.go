@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+	// handleConstraint is the unique index on handles, whatever their case.
+	handleConstraint = "users_handle_lower_idx"
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// Profile is the public face of a user. Unlike User it never includes the
+// email address.
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	// AvatarMediaID is set instead of AvatarURL when the avatar is an
+	// upload, served from /media/{id}.
+	AvatarMediaID *uuid.UUID `json:"avatar_media_id"`
+}
+
+// Author is the part of a profile embedded in every chirp.
+type Author struct {
+	ID            uuid.UUID  `json:"id"`
+	Handle        string     `json:"handle"`
+	DisplayName   string     `json:"display_name"`
+	AvatarURL     string     `json:"avatar_url"`
+	AvatarMediaID *uuid.UUID `json:"avatar_media_id"`
+}
+
+func toProfile(user database.User) Profile {
+	return Profile{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     user.AvatarUrl,
+		AvatarMediaID: uuidOrNil(user.AvatarMediaID),
+	}
+}
+
+// validateProfile checks the profile fields a user is changing. Nil fields
+// aren't being changed; empty ones clear everything but the handle.
+func validateProfile(handle, displayName, bio, avatarURL *string) error {
+	if handle != nil && !handlePattern.MatchString(*handle) {
+		return errors.New("Handle must be 3 to 30 letters, digits or underscores")
+	}
+	if displayName != nil && utf8.RuneCountInString(*displayName) > maxDisplayNameLength {
+		return fmt.Errorf("Display name can be at most %d characters", maxDisplayNameLength)
+	}
+	if bio != nil && utf8.RuneCountInString(*bio) > maxBioLength {
+		return fmt.Errorf("Bio can be at most %d characters", maxBioLength)
+	}
+	if avatarURL != nil && *avatarURL != "" {
+		u, err := url.Parse(*avatarURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(*avatarURL) > maxAvatarURLLength {
+			return errors.New("Avatar URL must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// getAuthors looks up the authors of a list of chirps with one query.
+func (cfg *apiConfig) getAuthors(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]Author, error) {
+	rows, err := cfg.db.GetUserProfilesByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	authors := map[uuid.UUID]Author{}
+	for _, row := range rows {
+		authors[row.ID] = Author{
+			ID:            row.ID,
+			Handle:        row.Handle,
+			DisplayName:   row.DisplayName,
+			AvatarURL:     row.AvatarUrl,
+			AvatarMediaID: uuidOrNil(row.AvatarMediaID),
+		}
+	}
+	return authors, nil
+}
+
+func (cfg *apiConfig) handlerGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	cfg.respondWithProfile(w, user, err)
+}
+
+func (cfg *apiConfig) handlerGetUserProfileByHandle(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.db.GetUserByHandle(r.Context(), r.PathValue("handle"))
+	cfg.respondWithProfile(w, user, err)
+}
+
+func (cfg *apiConfig) respondWithProfile(w http.ResponseWriter, user database.User, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "Unable to find user", err)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving user", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toProfile(user))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/profanity"
+)
+
+// plainRechirpConstraint is the unique index that allows each user one
+// plain rechirp of a chirp.
+const plainRechirpConstraint = "chirps_plain_rechirp_idx"
+
+// handlerRechirp shares another chirp, optionally with commentary in the
+// body. A rechirp without a body is a plain repost: each user can only make
+// one of those per chirp, so repeating the request returns the existing one.
+// Plain rechirps are deleted along with the chirp they point at; quotes
+// stay, pointing at nothing.
+func (cfg *apiConfig) handlerRechirp(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Body string `json:"body"`
+	}{}
+	if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	filter, err := cfg.loadProfanityFilter(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error loading word filter", err)
+		return
+	}
+	var checked profanity.Result
+	if req.Body != "" {
+		checked, err = validateChirpBody(req.Body, filter)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+	body := checked.Body
+	original, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find Chirp", err)
+		return
+	}
+	// Rechirping a plain rechirp shares the chirp it points at instead.
+	if original.Body == "" && original.RechirpOf.Valid {
+		original, err = cfg.db.GetChirpByID(r.Context(), original.RechirpOf.UUID)
+		if err != nil {
+			respondError(w, http.StatusNotFound, "Unable to find Chirp", err)
+			return
+		}
+	}
+	rechirpOf := uuid.NullUUID{UUID: original.ID, Valid: true}
+
+	plainRechirp := database.GetPlainRechirpParams{UserID: userID, RechirpOf: rechirpOf}
+
+	if body == "" {
+		existing, err := cfg.db.GetPlainRechirp(r.Context(), plainRechirp)
+		if err == nil {
+			cfg.respondWithRechirp(w, r, existing, http.StatusOK)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondError(w, http.StatusInternalServerError, "Error retrieving Chirp", err)
+			return
+		}
+	}
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	rechirp, err := qtx.AddRechirp(r.Context(), database.AddRechirpParams{
+		Body:      body,
+		UserID:    userID,
+		RechirpOf: rechirpOf,
+	})
+	// Another request for the same plain rechirp got there first.
+	if uniqueViolation(err) == plainRechirpConstraint {
+		tx.Rollback()
+		existing, err := cfg.db.GetPlainRechirp(r.Context(), plainRechirp)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error retrieving Chirp", err)
+			return
+		}
+		cfg.respondWithRechirp(w, r, existing, http.StatusOK)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error adding Chirp to database", err)
+		return
+	}
+	if err = saveChirpEntities(r.Context(), qtx, rechirp); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving hashtags and mentions", err)
+		return
+	}
+	if err = flagChirp(r.Context(), qtx, rechirp.ID, checked.Flagged); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error flagging Chirp for review", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error adding Chirp to database", err)
+		return
+	}
+	cfg.respondWithRechirp(w, r, rechirp, http.StatusCreated)
+}
+
+func (cfg *apiConfig) respondWithRechirp(w http.ResponseWriter, r *http.Request, rechirp database.Chirp, status int) {
+	viewerID := uuid.NullUUID{UUID: rechirp.UserID, Valid: true}
+	response, err := cfg.toChirps(r.Context(), []database.Chirp{rechirp}, viewerID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving Chirp", err)
+		return
+	}
+	respondWithJSON(w, status, response[0])
+}
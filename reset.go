@@ -4,6 +4,8 @@ import (
 	"net/http"
 )
 
+// handlerReset wipes every user, so on top of needing an admin it is only
+// available on a development platform.
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
 		w.WriteHeader(http.StatusForbidden)
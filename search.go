@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+// rankCursor marks the last chirp of a page of search results ordered by
+// relevance, which are keyed on (rank, id) rather than (created_at, id).
+type rankCursor struct {
+	Rank float32
+	ID   uuid.UUID
+}
+
+func encodeRankCursor(rank float32, id uuid.UUID) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRankCursor(cursor string) (rankCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return rankCursor{}, err
+	}
+	rankStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return rankCursor{}, fmt.Errorf("malformed cursor")
+	}
+	rank, err := strconv.ParseFloat(rankStr, 32)
+	if err != nil {
+		return rankCursor{}, err
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return rankCursor{}, err
+	}
+	return rankCursor{Rank: float32(rank), ID: id}, nil
+}
+
+// handlerSearchChirps runs a full-text search over chirp bodies. Results are
+// ordered by relevance unless `sort` asks for asc or desc, in which case
+// they page exactly like GET /api/chirps.
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID := viewerFromContext(r.Context())
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		respondError(w, http.StatusBadRequest, "Search query must not be empty", nil)
+		return
+	}
+	authorID := uuid.NullUUID{}
+	if userID := query.Get("author_id"); userID != "" {
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid author_id", err)
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var chirps []database.Chirp
+	switch query.Get("sort") {
+	case "", "relevance":
+		params, err := parseRankPage(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		params.Query = q
+		params.AuthorID = authorID
+		chirps, err = cfg.searchChirpsByRank(w, r, params)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error searching Chirps", err)
+			return
+		}
+	default:
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		if page.descending {
+			chirps, err = cfg.db.SearchChirpsDesc(r.Context(), database.SearchChirpsDescParams{
+				Query:           q,
+				AuthorID:        authorID,
+				CursorCreatedAt: page.cursorCreatedAt(),
+				CursorID:        page.cursorID(),
+				PageLimit:       page.queryLimit(),
+			})
+		} else {
+			chirps, err = cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+				Query:           q,
+				AuthorID:        authorID,
+				CursorCreatedAt: page.cursorCreatedAt(),
+				CursorID:        page.cursorID(),
+				PageLimit:       page.queryLimit(),
+			})
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error searching Chirps", err)
+			return
+		}
+		chirps = page.trim(w, r, chirps)
+	}
+	response, err := cfg.toChirps(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error searching Chirps", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// parseRankPage reads `limit` and `cursor` for results ordered by relevance.
+// The returned limit already includes the extra row used to detect a next
+// page, as with pageRequest.queryLimit.
+func parseRankPage(r *http.Request) (database.SearchChirpsByRankParams, error) {
+	query := r.URL.Query()
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		return database.SearchChirpsByRankParams{}, err
+	}
+	params := database.SearchChirpsByRankParams{PageLimit: limit + 1}
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		cursor, err := decodeRankCursor(cursorStr)
+		if err != nil {
+			return database.SearchChirpsByRankParams{}, fmt.Errorf("invalid cursor")
+		}
+		params.CursorRank = sql.NullFloat64{Float64: float64(cursor.Rank), Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+	return params, nil
+}
+
+// searchChirpsByRank fetches one page of results ordered by relevance and
+// sets the Link header for the next one.
+func (cfg *apiConfig) searchChirpsByRank(w http.ResponseWriter, r *http.Request, params database.SearchChirpsByRankParams) ([]database.Chirp, error) {
+	rows, err := cfg.db.SearchChirpsByRank(r.Context(), params)
+	if err != nil {
+		return nil, err
+	}
+	limit := params.PageLimit - 1
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		setNextPageLink(w, r, encodeRankCursor(last.Rank, last.ID))
+	}
+	chirps := []database.Chirp{}
+	for _, row := range rows {
+		chirps = append(chirps, database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			ParentID:  row.ParentID,
+			RechirpOf: row.RechirpOf,
+		})
+	}
+	return chirps, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+// Session is one login, identified by the refresh token family it started.
+// Rotating the refresh token keeps the same session.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// clientIP returns the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	rows, err := cfg.db.GetSessions(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error retrieving sessions", err)
+		return
+	}
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, toSession(row))
+	}
+	// Most recently used first; a session that was never refreshed counts
+	// as last used when it started.
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].lastActive().After(sessions[j].lastActive())
+	})
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Error parsing ID", err)
+		return
+	}
+	revoked, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		UserID:   userID,
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking session", err)
+		return
+	}
+	if revoked == 0 {
+		respondError(w, http.StatusNotFound, "Unable to find session", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	if err := cfg.signOutEverywhere(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLogout revokes the access token the request was made with, and the
+// session of the refresh token in the body if there is one.
+func (cfg *apiConfig) handlerLogout(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	userID, _ := auth.UserIDFromContext(r.Context())
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	if req.RefreshToken != "" {
+		if err := cfg.db.RevokeSessionByToken(r.Context(), database.RevokeSessionByTokenParams{
+			UserID: userID,
+			Token:  req.RefreshToken,
+		}); err != nil {
+			respondError(w, http.StatusInternalServerError, "Error revoking session", err)
+			return
+		}
+	}
+	if err := cfg.revocations.revokeToken(r.Context(), claims); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking token", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// signOutEverywhere ends every session the user has and revokes the access
+// tokens already handed out to them.
+func (cfg *apiConfig) signOutEverywhere(ctx context.Context, userID uuid.UUID) error {
+	if err := cfg.db.RevokeAllSessions(ctx, userID); err != nil {
+		return err
+	}
+	return cfg.revocations.revokeUser(ctx, userID)
+}
+
+func toSession(row database.GetSessionsRow) Session {
+	session := Session{
+		ID:        row.FamilyID,
+		UserAgent: row.UserAgent,
+		IPAddress: row.IpAddress,
+		CreatedAt: row.StartedAt,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if row.LastUsedAt.Valid {
+		session.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return session
+}
+
+func (s Session) lastActive() time.Time {
+	if s.LastUsedAt != nil {
+		return *s.LastUsedAt
+	}
+	return s.CreatedAt
+}
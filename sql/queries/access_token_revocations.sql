@@ -0,0 +1,16 @@
+-- name: RevokeAccessToken :exec
+INSERT INTO access_token_revocations(user_id, jti, revoked_at, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeUserAccessTokens :exec
+INSERT INTO access_token_revocations(user_id, jti, revoked_at, expires_at)
+VALUES ($1, NULL, $2, $3);
+
+-- name: GetActiveAccessTokenRevocations :many
+SELECT * FROM access_token_revocations
+WHERE expires_at > NOW();
+
+-- name: DeleteExpiredAccessTokenRevocations :exec
+DELETE FROM access_token_revocations
+WHERE expires_at <= NOW();
@@ -1,7 +1,24 @@
--- name: UpdateUserCredentials :one
+-- name: UpdateUser :one
 UPDATE users
-SET email=$2,
-    hashed_password=$3,
+SET email=COALESCE(sqlc.narg(email), email),
+    hashed_password=COALESCE(sqlc.narg(hashed_password), hashed_password),
+    handle=COALESCE(sqlc.narg(handle), handle),
+    display_name=COALESCE(sqlc.narg(display_name), display_name),
+    bio=COALESCE(sqlc.narg(bio), bio),
+    avatar_url=COALESCE(sqlc.narg(avatar_url), avatar_url),
+    avatar_media_id=CASE WHEN sqlc.narg(avatar_url)::text IS NULL THEN avatar_media_id ELSE sqlc.narg(avatar_media_id)::uuid END,
+    email_verified=(email_verified AND email=COALESCE(sqlc.narg(email), email)),
     updated_at=NOW()
-WHERE id=$1
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+WHERE id=sqlc.arg(id)
+RETURNING id, created_at, updated_at, email, is_chirpy_red, role, email_verified, handle, display_name, bio, avatar_url, avatar_media_id;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password=$2,
+    updated_at=NOW()
+WHERE id=$1;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password=sqlc.arg(new_hash)
+WHERE id=sqlc.arg(id) AND hashed_password=sqlc.arg(old_hash);
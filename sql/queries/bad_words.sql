@@ -0,0 +1,47 @@
+-- name: GetBadWords :many
+SELECT *
+FROM bad_words
+ORDER BY word ASC;
+
+-- name: SetBadWord :one
+INSERT INTO bad_words(
+    word,
+    action,
+    created_at,
+    updated_at
+) VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW()
+) ON CONFLICT (word) DO UPDATE
+SET action = EXCLUDED.action,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteBadWord :execrows
+DELETE FROM bad_words
+WHERE word = $1;
+
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags(
+    chirp_id,
+    words,
+    created_at
+) VALUES (
+    $1,
+    $2,
+    NOW()
+) ON CONFLICT (chirp_id) DO UPDATE
+SET words = EXCLUDED.words,
+    created_at = NOW();
+
+-- name: UnflagChirp :exec
+DELETE FROM chirp_flags
+WHERE chirp_id = $1;
+
+-- name: GetFlaggedChirps :many
+SELECT chirps.*, chirp_flags.words
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+ORDER BY chirp_flags.created_at ASC;
@@ -0,0 +1,78 @@
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags(
+    chirp_id,
+    tag,
+    start_offset,
+    end_offset
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: AddChirpMention :exec
+INSERT INTO chirp_mentions(
+    chirp_id,
+    mention,
+    user_id,
+    start_offset,
+    end_offset
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: GetHashtagsForChirps :many
+SELECT *
+FROM chirp_hashtags
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: GetMentionsForChirps :many
+SELECT *
+FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: GetChirpsByHashtag :many
+SELECT *
+FROM chirps
+WHERE id IN (
+        SELECT chirp_id FROM chirp_hashtags WHERE tag = sqlc.arg(tag)
+    )
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetChirpsByHashtagDesc :many
+SELECT *
+FROM chirps
+WHERE id IN (
+        SELECT chirp_id FROM chirp_hashtags WHERE tag = sqlc.arg(tag)
+    )
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetTrendingHashtags :many
+SELECT chirp_hashtags.tag, COUNT(DISTINCT chirp_hashtags.chirp_id) AS chirp_count
+FROM chirp_hashtags
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at >= sqlc.arg(since)
+GROUP BY chirp_hashtags.tag
+ORDER BY chirp_count DESC, chirp_hashtags.tag ASC
+LIMIT sqlc.arg(tag_limit);
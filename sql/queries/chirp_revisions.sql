@@ -0,0 +1,18 @@
+-- name: AddChirpRevision :exec
+INSERT INTO chirp_revisions(
+    id,
+    created_at,
+    chirp_id,
+    body
+) VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+);
+
+-- name: GetChirpRevisions :many
+SELECT *
+FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY created_at ASC;
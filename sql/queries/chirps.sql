@@ -4,19 +4,24 @@ INSERT INTO chirps(
     created_at,
     updated_at,
     body,
-    user_id
+    user_id,
+    parent_id
 ) VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 ) RETURNING *;
 
 -- name: GetAllChirps :many
 SELECT *
 FROM chirps
-ORDER BY created_at ASC;
+WHERE sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
 
 -- name: GetChirpByID :one
 SELECT *
@@ -30,5 +35,80 @@ WHERE id = $1;
 -- name: GetChirpsByUser :many
 SELECT *
 FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
\ No newline at end of file
+WHERE user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetAllChirpsDesc :many
+SELECT *
+FROM chirps
+WHERE sqlc.narg(cursor_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetChirpsByUserDesc :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetChirpByIDForUpdate :one
+SELECT *
+FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpReplies :many
+SELECT *
+FROM chirps
+WHERE parent_id = sqlc.arg(parent_id)
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetReplyCounts :many
+SELECT parent_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE parent_id = ANY(sqlc.arg(parent_ids)::uuid[])
+GROUP BY parent_id;
+
+-- name: GetFeed :many
+SELECT *
+FROM chirps
+WHERE user_id IN (
+        SELECT followed_id FROM follows WHERE follower_id = sqlc.arg(follower_id)
+    )
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetFeedDesc :many
+SELECT *
+FROM chirps
+WHERE user_id IN (
+        SELECT followed_id FROM follows WHERE follower_id = sqlc.arg(follower_id)
+    )
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetChirpsByIDs :many
+SELECT *
+FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
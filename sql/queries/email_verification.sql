@@ -0,0 +1,21 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens(token_hash, user_id, email, created_at, expires_at)
+VALUES ($1, $2, $3, NOW(), $4);
+
+-- name: DeleteEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens
+WHERE user_id = $1;
+
+-- name: ConsumeEmailVerificationToken :one
+DELETE FROM email_verification_tokens
+WHERE token_hash = $1
+    AND expires_at > NOW()
+RETURNING user_id, email;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified = TRUE,
+    updated_at = NOW()
+WHERE id = $1
+    AND email = $2
+RETURNING id, created_at, updated_at, email, is_chirpy_red, role, email_verified, handle, display_name, bio, avatar_url, avatar_media_id;
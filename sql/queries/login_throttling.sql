@@ -0,0 +1,38 @@
+-- name: EnsureLoginThrottle :exec
+INSERT INTO login_throttles(throttle_key, last_failed_at)
+VALUES ($1, $2)
+ON CONFLICT (throttle_key) DO NOTHING;
+
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE throttle_key = $1;
+
+-- name: GetLoginThrottleForUpdate :one
+SELECT * FROM login_throttles
+WHERE throttle_key = $1
+FOR UPDATE;
+
+-- name: UpdateLoginThrottle :exec
+UPDATE login_throttles
+SET failures = $2,
+    last_failed_at = $3,
+    locked_until = $4
+WHERE throttle_key = $1;
+
+-- name: DeleteLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE throttle_key = $1;
+
+-- name: DeleteStaleLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE last_failed_at < $1
+    AND (locked_until IS NULL OR locked_until < $1);
+
+-- name: RecordLoginLockout :exec
+INSERT INTO login_lockouts(created_at, kind, subject, ip_address, locked_until)
+VALUES (NOW(), $1, $2, $3, $4);
+
+-- name: GetLoginLockouts :many
+SELECT * FROM login_lockouts
+ORDER BY created_at DESC
+LIMIT $1;
@@ -0,0 +1,66 @@
+-- name: CreateMedia :one
+INSERT INTO media(
+    id,
+    created_at,
+    user_id,
+    content_type,
+    size_bytes,
+    width,
+    height,
+    thumbnail_content_type
+) VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+) RETURNING *;
+
+-- name: DeleteUnattachedMedia :many
+DELETE FROM media
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+    AND NOT EXISTS (SELECT 1 FROM chirp_media WHERE chirp_media.media_id = media.id)
+    AND NOT EXISTS (SELECT 1 FROM users WHERE users.avatar_media_id = media.id)
+RETURNING id;
+
+-- name: GetMedia :one
+SELECT *
+FROM media
+WHERE id = $1;
+
+-- name: GetUserMediaForShare :many
+SELECT *
+FROM media
+WHERE user_id = sqlc.arg(user_id)
+    AND id = ANY(sqlc.arg(ids)::uuid[])
+FOR SHARE;
+
+-- name: AddChirpMedia :exec
+INSERT INTO chirp_media(
+    chirp_id,
+    media_id,
+    position
+) VALUES (
+    $1,
+    $2,
+    $3
+);
+
+-- name: GetMediaForChirps :many
+SELECT chirp_media.chirp_id, media.id, media.created_at, media.user_id, media.content_type, media.size_bytes, media.width, media.height, media.thumbnail_content_type
+FROM chirp_media
+JOIN media ON media.id = chirp_media.media_id
+WHERE chirp_media.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_media.chirp_id, chirp_media.position;
+
+-- name: LockUnattachedMedia :many
+SELECT id
+FROM media
+WHERE created_at < sqlc.arg(created_before)
+    AND NOT EXISTS (SELECT 1 FROM chirp_media WHERE chirp_media.media_id = media.id)
+    AND NOT EXISTS (SELECT 1 FROM users WHERE users.avatar_media_id = media.id)
+LIMIT 100
+FOR UPDATE SKIP LOCKED;
@@ -0,0 +1,27 @@
+-- name: AddRechirp :one
+INSERT INTO chirps(
+    id,
+    created_at,
+    updated_at,
+    body,
+    user_id,
+    rechirp_of
+) VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+) RETURNING *;
+
+-- name: GetPlainRechirp :one
+SELECT *
+FROM chirps
+WHERE user_id = $1 AND rechirp_of = $2 AND body = '';
+
+-- name: GetRechirpCounts :many
+SELECT rechirp_of, COUNT(*) AS rechirp_count
+FROM chirps
+WHERE rechirp_of = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY rechirp_of;
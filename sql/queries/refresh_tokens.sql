@@ -4,22 +4,45 @@ INSERT INTO refresh_tokens(
     created_at,
     updated_at,
     user_id,
-    expires_at
+    expires_at,
+    family_id,
+    user_agent,
+    ip_address,
+    last_used_at
 ) VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
-    $3
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW()
 ) RETURNING *;
 
--- name: GetUserFromRefreshToken :one
-SELECT user_id, expires_at, revoked_at 
+-- name: GetRefreshTokenForUpdate :one
+SELECT *
 FROM refresh_tokens
-WHERE token = $1;
+WHERE token = $1
+FOR UPDATE;
 
 -- name: RevokeToken :exec
 UPDATE refresh_tokens
 SET updated_at=NOW(),
     revoked_at=NOW()
-WHERE token=$1;
\ No newline at end of file
+WHERE token=$1;
+
+-- name: RotateRefreshToken :exec
+UPDATE refresh_tokens
+SET updated_at=NOW(),
+    revoked_at=COALESCE(revoked_at, NOW()),
+    rotated_at=NOW(),
+    last_used_at=NOW()
+WHERE token=$1;
+
+-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at=NOW(),
+    revoked_at=COALESCE(revoked_at, NOW())
+WHERE family_id=$1;
@@ -0,0 +1,30 @@
+-- name: SearchChirps :many
+SELECT *
+FROM chirps
+WHERE to_tsvector('english', body) @@ websearch_to_tsquery('english', sqlc.arg(query))
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id)::uuid)
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_limit);
+
+-- name: SearchChirpsDesc :many
+SELECT *
+FROM chirps
+WHERE to_tsvector('english', body) @@ websearch_to_tsquery('english', sqlc.arg(query))
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id)::uuid)
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: SearchChirpsByRank :many
+SELECT *, ts_rank(to_tsvector('english', body), websearch_to_tsquery('english', sqlc.arg(query))) AS rank
+FROM chirps
+WHERE to_tsvector('english', body) @@ websearch_to_tsquery('english', sqlc.arg(query))
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id)::uuid)
+    AND (sqlc.narg(cursor_rank)::real IS NULL
+        OR (ts_rank(to_tsvector('english', body), websearch_to_tsquery('english', sqlc.arg(query))), id)
+            < (sqlc.narg(cursor_rank)::real, sqlc.narg(cursor_id)::uuid))
+ORDER BY rank DESC, id DESC
+LIMIT sqlc.arg(page_limit);
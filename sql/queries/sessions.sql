@@ -0,0 +1,36 @@
+-- name: GetSessions :many
+SELECT DISTINCT ON (family_id)
+    family_id,
+    user_agent,
+    ip_address,
+    (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id)::timestamp AS started_at,
+    last_used_at,
+    expires_at
+FROM refresh_tokens
+WHERE user_id = $1
+    AND revoked_at IS NULL
+    AND expires_at > NOW()
+ORDER BY family_id, created_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET updated_at=NOW(),
+    revoked_at=NOW()
+WHERE user_id = $1
+    AND family_id = $2
+    AND revoked_at IS NULL;
+
+-- name: RevokeAllSessions :exec
+UPDATE refresh_tokens
+SET updated_at=NOW(),
+    revoked_at=NOW()
+WHERE user_id = $1
+    AND revoked_at IS NULL;
+
+-- name: RevokeSessionByToken :exec
+UPDATE refresh_tokens
+SET updated_at=NOW(),
+    revoked_at=NOW()
+WHERE user_id = $1
+    AND family_id = (SELECT family_id FROM refresh_tokens WHERE token = $2)
+    AND revoked_at IS NULL;
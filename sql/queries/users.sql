@@ -6,7 +6,7 @@ VALUES (
     NOW(),
     $1,
     $2
-) RETURNING id, created_at, updated_at, email, is_chirpy_red;
+) RETURNING id, created_at, updated_at, email, is_chirpy_red, role, email_verified, handle, display_name, bio, avatar_url, avatar_media_id;
 
 -- name: DeleteUsers :exec
 DELETE FROM users;
@@ -14,3 +14,34 @@ DELETE FROM users;
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE lower(handle) = lower($1);
+
+-- name: GetUserProfilesByIDs :many
+SELECT id, handle, display_name, avatar_url, avatar_media_id
+FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetUsersByHandles :many
+SELECT id, lower(handle) AS handle
+FROM users
+WHERE lower(handle) = ANY(sqlc.arg(handles)::text[]);
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, is_chirpy_red, role, email_verified, handle, display_name, bio, avatar_url, avatar_media_id;
+
+-- name: SetUserRoleByEmail :execrows
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE email = $1;
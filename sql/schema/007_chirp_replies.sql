@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN parent_id UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_parent_id_idx ON chirps(parent_id, created_at, id);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN parent_id;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- A quote is its author's own words, so it outlives the chirp it quotes.
+ALTER TABLE chirps
+ADD COLUMN rechirp_of UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_rechirp_of_idx ON chirps(rechirp_of);
+CREATE UNIQUE INDEX chirps_plain_rechirp_idx ON chirps(user_id, rechirp_of) WHERE body = '';
+
+-- A plain rechirp has nothing left to show once the original is gone,
+-- however it was deleted.
+-- +goose StatementBegin
+CREATE FUNCTION delete_plain_rechirps() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM chirps WHERE rechirp_of = OLD.id AND body = '';
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_delete_plain_rechirps
+BEFORE DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION delete_plain_rechirps();
+
+-- +goose Down
+DROP TRIGGER chirps_delete_plain_rechirps ON chirps;
+DROP FUNCTION delete_plain_rechirps;
+
+ALTER TABLE chirps
+DROP COLUMN rechirp_of;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE bad_words(
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO bad_words(word, action, created_at, updated_at)
+VALUES ('kerfuffle', 'mask', NOW(), NOW()),
+       ('sharbert', 'mask', NOW(), NOW()),
+       ('fornax', 'mask', NOW(), NOW());
+
+CREATE TABLE chirp_flags(
+    chirp_id UUID NOT NULL PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    words TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE bad_words;
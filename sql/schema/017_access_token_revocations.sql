@@ -0,0 +1,16 @@
+-- +goose Up
+-- A row with a jti revokes that one access token. A row without one revokes
+-- every access token issued to the user up to revoked_at. Rows are only
+-- needed until the tokens they cover would have expired anyway.
+CREATE TABLE access_token_revocations(
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    jti UUID UNIQUE,
+    revoked_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX access_token_revocations_expires_at_idx ON access_token_revocations(expires_at);
+
+-- +goose Down
+DROP TABLE access_token_revocations;
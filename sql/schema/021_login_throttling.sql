@@ -0,0 +1,25 @@
+-- +goose Up
+-- One row per email address or IP address with recent failed logins.
+CREATE TABLE login_throttles(
+    throttle_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX login_throttles_last_failed_at_idx ON login_throttles(last_failed_at);
+
+CREATE TABLE login_lockouts(
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('email', 'ip')),
+    subject TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    locked_until TIMESTAMP NOT NULL
+);
+
+CREATE INDEX login_lockouts_created_at_idx ON login_lockouts(created_at);
+
+-- +goose Down
+DROP TABLE login_lockouts;
+DROP TABLE login_throttles;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Existing and new users get a random handle until they choose one.
+ALTER TABLE users
+ADD COLUMN handle TEXT NOT NULL DEFAULT ('user_' || left(replace(gen_random_uuid()::text, '-', ''), 12)),
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+-- Handles keep the case they were chosen in but are unique regardless of it.
+CREATE UNIQUE INDEX users_handle_lower_idx ON users(lower(handle));
+
+-- Mentions are linked by handle from now on. Linking them by email told
+-- anyone who mentioned an address whose account it was.
+UPDATE chirp_mentions SET user_id = NULL WHERE mention LIKE '%@%';
+
+-- +goose Down
+DROP INDEX users_handle_lower_idx;
+
+ALTER TABLE users
+DROP COLUMN avatar_url,
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- Uploaded images. The files themselves live in object storage, keyed by
+-- id, with the thumbnail (when there is one) beside them.
+CREATE TABLE media(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    thumbnail_content_type TEXT DEFAULT NULL
+);
+
+CREATE INDEX media_user_id_idx ON media(user_id);
+
+CREATE TABLE chirp_media(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL CHECK (position >= 0 AND position < 4),
+    PRIMARY KEY (chirp_id, position),
+    UNIQUE (chirp_id, media_id)
+);
+
+CREATE INDEX chirp_media_media_id_idx ON chirp_media(media_id);
+
+-- An avatar chosen from the user's uploads. avatar_url is left for URLs
+-- the user gives.
+ALTER TABLE users
+ADD COLUMN avatar_media_id UUID REFERENCES media(id) ON DELETE SET NULL;
+
+CREATE INDEX users_avatar_media_id_idx ON users(avatar_media_id);
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN avatar_media_id;
+
+DROP TABLE chirp_media;
+DROP TABLE media;
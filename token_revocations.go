@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+)
+
+// accessTokenLifetime is how long an access token stays valid, and so how
+// long a revocation has to be remembered.
+const accessTokenLifetime = time.Hour
+
+// revocationRefreshInterval is how often each instance reloads the
+// revocation list, which bounds how long a revocation made by another
+// instance takes to apply here.
+const revocationRefreshInterval = 30 * time.Second
+
+// tokenRevocations is the access token revocation list checked by the auth
+// middleware. The database is the source of truth; the in-memory copy saves
+// a query per request. Revocations made by this instance apply to the copy
+// immediately.
+type tokenRevocations struct {
+	db *database.Queries
+
+	mu       sync.RWMutex
+	tokens   map[uuid.UUID]bool
+	users    map[uuid.UUID]time.Time
+	loadedAt time.Time
+}
+
+func newTokenRevocations(db *database.Queries) *tokenRevocations {
+	return &tokenRevocations{
+		db:     db,
+		tokens: map[uuid.UUID]bool{},
+		users:  map[uuid.UUID]time.Time{},
+	}
+}
+
+func (t *tokenRevocations) IsRevoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	if err := t.refresh(ctx); err != nil {
+		return false, err
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if jti := claims.TokenID(); jti != uuid.Nil && t.tokens[jti] {
+		return true, nil
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return false, err
+	}
+	revokedAt, ok := t.users[userID]
+	return ok && claims.IssuedBefore(revokedAt), nil
+}
+
+// revokeToken revokes a single access token, as on logout.
+func (t *tokenRevocations) revokeToken(ctx context.Context, claims *auth.Claims) error {
+	jti := claims.TokenID()
+	if jti == uuid.Nil {
+		return nil
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().UTC().Add(accessTokenLifetime)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if err := t.db.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+		UserID:    userID,
+		Jti:       uuid.NullUUID{UUID: jti, Valid: true},
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.tokens[jti] = true
+	t.mu.Unlock()
+	return nil
+}
+
+// revokeUser revokes every access token issued to the user so far.
+func (t *tokenRevocations) revokeUser(ctx context.Context, userID uuid.UUID) error {
+	revokedAt := time.Now().UTC()
+	if err := t.db.RevokeUserAccessTokens(ctx, database.RevokeUserAccessTokensParams{
+		UserID:    userID,
+		RevokedAt: revokedAt,
+		ExpiresAt: revokedAt.Add(accessTokenLifetime),
+	}); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.users[userID] = revokedAt
+	t.mu.Unlock()
+	return nil
+}
+
+// refresh reloads the in-memory copy once it is older than
+// revocationRefreshInterval. If the database can't be reached the stale
+// copy is kept rather than locking everyone out.
+func (t *tokenRevocations) refresh(ctx context.Context) error {
+	t.mu.RLock()
+	fresh := time.Since(t.loadedAt) < revocationRefreshInterval
+	t.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.loadedAt) < revocationRefreshInterval {
+		return nil
+	}
+	if err := t.load(ctx); err != nil {
+		if t.loadedAt.IsZero() {
+			return err
+		}
+		log.Printf("Error reloading token revocations, keeping the old list: %s", err)
+	}
+	t.loadedAt = time.Now()
+	return nil
+}
+
+func (t *tokenRevocations) load(ctx context.Context) error {
+	if err := t.db.DeleteExpiredAccessTokenRevocations(ctx); err != nil {
+		return err
+	}
+	rows, err := t.db.GetActiveAccessTokenRevocations(ctx)
+	if err != nil {
+		return err
+	}
+	tokens := map[uuid.UUID]bool{}
+	users := map[uuid.UUID]time.Time{}
+	for _, row := range rows {
+		if row.Jti.Valid {
+			tokens[row.Jti.UUID] = true
+			continue
+		}
+		if row.RevokedAt.After(users[row.UserID]) {
+			users[row.UserID] = row.RevokedAt
+		}
+	}
+	t.tokens, t.users = tokens, users
+	return nil
+}
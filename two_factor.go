@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/totp"
+)
+
+const (
+	totpIssuer                = "Chirpy"
+	recoveryCodeCount         = 10
+	loginChallengeTTL         = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+)
+
+// TwoFactorEnrollment is returned once when enrolling. The recovery codes
+// are only stored hashed, so this is the only time they can be shown.
+type TwoFactorEnrollment struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// LoginChallenge is returned by handlerLogin instead of tokens when the
+// user has two-factor authentication enabled.
+type LoginChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// handlerStartTwoFactor generates a new TOTP secret and recovery codes. 2FA
+// only takes effect once handlerConfirmTwoFactor has seen a code from the
+// authenticator app; until then this can be called again to start over.
+func (cfg *apiConfig) handlerStartTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Unable to find user", err)
+		return
+	}
+	enabled, err := cfg.twoFactorEnabled(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking two-factor authentication", err)
+		return
+	}
+	if enabled {
+		respondError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error generating secret", err)
+		return
+	}
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = totp.GenerateRecoveryCode()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error generating recovery codes", err)
+			return
+		}
+		hashes[i] = auth.HashToken(totp.NormalizeRecoveryCode(codes[i]))
+	}
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if err = qtx.StartTOTPEnrollment(r.Context(), database.StartTOTPEnrollmentParams{
+		UserID: userID,
+		Secret: secret,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving secret", err)
+		return
+	}
+	if err = qtx.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving recovery codes", err)
+		return
+	}
+	if err = qtx.AddRecoveryCodes(r.Context(), database.AddRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: hashes,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving recovery codes", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error saving secret", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, TwoFactorEnrollment{
+		Secret:        secret,
+		OTPAuthURI:    totp.URI(totpIssuer, user.Email, secret),
+		RecoveryCodes: codes,
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Code string `json:"code"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	enrollment, err := qtx.GetUserTOTPForUpdate(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Start two-factor enrollment first", err)
+		return
+	}
+	if enrollment.ConfirmedAt.Valid {
+		respondError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+	step, ok := totp.Validate(enrollment.Secret, req.Code, time.Now())
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid code", nil)
+		return
+	}
+	if err = qtx.ConfirmTOTPEnrollment(r.Context(), database.ConfirmTOTPEnrollmentParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error enabling two-factor authentication", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error enabling two-factor authentication", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDisableTwoFactor turns 2FA off. It takes a current code, or a
+// recovery code, so a stolen access token alone can't do it. Wrong codes
+// count against the login throttles, or the token would be enough to
+// guess one.
+func (cfg *apiConfig) handlerDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		Code string `json:"code"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	enrollment, err := qtx.GetUserTOTPForUpdate(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Two-factor authentication is not enabled", err)
+		return
+	}
+	if enrollment.ConfirmedAt.Valid {
+		user, err := qtx.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error retrieving user", err)
+			return
+		}
+		attempt, wait, err := cfg.startLoginAttempt(r.Context(), loginThrottles(user.Email, clientIP(r)), clientIP(r))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error checking login attempts", err)
+			return
+		}
+		if wait > 0 {
+			respondTooManyLoginAttempts(w, wait)
+			return
+		}
+		ok, err := checkSecondFactor(r.Context(), qtx, enrollment, req.Code)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error checking code", err)
+			return
+		}
+		if ok {
+			wait, err = attempt.check(r.Context())
+		} else {
+			wait, err = attempt.fail(r.Context())
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error recording attempt", err)
+			return
+		}
+		if wait > 0 {
+			respondTooManyLoginAttempts(w, wait)
+			return
+		}
+		if !ok {
+			respondError(w, http.StatusForbidden, "Invalid code", nil)
+			return
+		}
+	}
+	if err = qtx.DeleteUserTOTP(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+	if err = qtx.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLoginTwoFactor is the second step of logging in: it trades the
+// challenge token from handlerLogin and a TOTP or recovery code for the
+// usual access and refresh tokens.
+func (cfg *apiConfig) handlerLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	req := struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}{}
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
+		return
+	}
+	tokenHash := auth.HashToken(req.ChallengeToken)
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	challenge, err := qtx.GetLoginChallengeForUpdate(r.Context(), tokenHash)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Login challenge is invalid or has expired", err)
+		return
+	}
+	user, err := qtx.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Login challenge is invalid or has expired", err)
+		return
+	}
+	// Wrong codes count against the same throttles as wrong passwords, or
+	// someone with the password could take a fresh challenge every few
+	// guesses and keep going.
+	attempt, wait, err := cfg.startLoginAttempt(r.Context(), loginThrottles(user.Email, clientIP(r)), clientIP(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking login attempts", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyLoginAttempts(w, wait)
+		return
+	}
+	enrollment, err := qtx.GetUserTOTPForUpdate(r.Context(), challenge.UserID)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Login challenge is invalid or has expired", err)
+		return
+	}
+	ok, err := checkSecondFactor(r.Context(), qtx, enrollment, req.Code)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking code", err)
+		return
+	}
+	if !ok {
+		// Six digits don't take long to guess, so each challenge only gets
+		// a few tries before the password has to be entered again.
+		if challenge.Attempts+1 >= maxLoginChallengeAttempts {
+			err = qtx.DeleteLoginChallenge(r.Context(), tokenHash)
+		} else {
+			err = qtx.RecordLoginChallengeAttempt(r.Context(), tokenHash)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err == nil {
+			wait, err = attempt.fail(r.Context())
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error recording attempt", err)
+			return
+		}
+		if wait > 0 {
+			respondTooManyLoginAttempts(w, wait)
+			return
+		}
+		respondError(w, http.StatusUnauthorized, "Invalid code", nil)
+		return
+	}
+	// The code is only spent if the attempt didn't have to wait, since the
+	// transaction using it up is rolled back otherwise.
+	wait, err = attempt.succeed(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error recording login attempt", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyLoginAttempts(w, wait)
+		return
+	}
+	if err = qtx.DeleteLoginChallenge(r.Context(), tokenHash); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error completing login", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error completing login", err)
+		return
+	}
+	cfg.completeLogin(w, r, user)
+}
+
+// startLoginChallenge answers a correct password from a user with 2FA
+// enabled.
+func (cfg *apiConfig) startLoginChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	if err := cfg.db.DeleteExpiredLoginChallenges(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting login challenge", err)
+		return
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting login challenge", err)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(loginChallengeTTL)
+	if err = cfg.db.CreateLoginChallenge(r.Context(), database.CreateLoginChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting login challenge", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, LoginChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+	})
+}
+
+func (cfg *apiConfig) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	enrollment, err := cfg.db.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return enrollment.ConfirmedAt.Valid, nil
+}
+
+// checkSecondFactor accepts a TOTP code that hasn't been used yet, or an
+// unused recovery code, using it up either way. enrollment must have been
+// read FOR UPDATE in the same transaction as q.
+func checkSecondFactor(ctx context.Context, q *database.Queries, enrollment database.UserTotp, code string) (bool, error) {
+	if step, ok := totp.Validate(enrollment.Secret, code, time.Now()); ok && step > enrollment.LastUsedStep {
+		err := q.SetTOTPLastUsedStep(ctx, database.SetTOTPLastUsedStepParams{
+			UserID:       enrollment.UserID,
+			LastUsedStep: step,
+		})
+		return err == nil, err
+	}
+	used, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   enrollment.UserID,
+		CodeHash: auth.HashToken(totp.NormalizeRecoveryCode(code)),
+	})
+	if err != nil {
+		return false, err
+	}
+	return used > 0, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/totp"
+)
+
+const (
+	testEmail    = "walt@example.com"
+	testPassword = "correct horse battery staple"
+)
+
+// enrollTwoFactor signs up a user and turns on 2FA for them, returning an
+// access token from before 2FA was on, the TOTP secret, the step of the
+// code used to confirm it and the recovery codes.
+func enrollTwoFactor(t *testing.T, cfg *apiConfig) (string, string, int64, []string) {
+	t.Helper()
+	credentials := map[string]string{"email": testEmail, "password": testPassword}
+	if rec := serveJSON(t, http.HandlerFunc(cfg.handlerAddUser), http.MethodPost, "", credentials, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("signing up: %d %s", rec.Code, rec.Body)
+	}
+	var user User
+	if rec := serveJSON(t, http.HandlerFunc(cfg.handlerLogin), http.MethodPost, "", credentials, &user); rec.Code != http.StatusOK {
+		t.Fatalf("logging in: %d %s", rec.Code, rec.Body)
+	}
+	requireUser := auth.NewMiddleware(cfg.keys, cfg.revocations).Required
+	var enrollment TwoFactorEnrollment
+	if rec := serveJSON(t, requireUser(http.HandlerFunc(cfg.handlerStartTwoFactor)), http.MethodPost, user.Token, nil, &enrollment); rec.Code != http.StatusCreated {
+		t.Fatalf("starting 2FA: %d %s", rec.Code, rec.Body)
+	}
+	step := totp.Step(time.Now())
+	code := totpCode(t, enrollment.Secret, step)
+	if rec := serveJSON(t, requireUser(http.HandlerFunc(cfg.handlerConfirmTwoFactor)), http.MethodPost, user.Token, map[string]string{"code": code}, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("confirming 2FA: %d %s", rec.Code, rec.Body)
+	}
+	return user.Token, enrollment.Secret, step, enrollment.RecoveryCodes
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatalf("making code: %v", err)
+	}
+	return code
+}
+
+// startChallenge logs in with the password, which only gets as far as a
+// challenge for users with 2FA.
+func startChallenge(t *testing.T, cfg *apiConfig) string {
+	t.Helper()
+	var challenge LoginChallenge
+	credentials := map[string]string{"email": testEmail, "password": testPassword}
+	rec := serveJSON(t, http.HandlerFunc(cfg.handlerLogin), http.MethodPost, "", credentials, &challenge)
+	if rec.Code != http.StatusOK || !challenge.TwoFactorRequired {
+		t.Fatalf("expected a challenge, got %d %s", rec.Code, rec.Body)
+	}
+	return challenge.ChallengeToken
+}
+
+func answerChallenge(t *testing.T, cfg *apiConfig, token, code string) int {
+	t.Helper()
+	req := map[string]string{"challenge_token": token, "code": code}
+	return serveJSON(t, http.HandlerFunc(cfg.handlerLoginTwoFactor), http.MethodPost, "", req, nil).Code
+}
+
+func TestLoginTwoFactorRejectsReplayedCode(t *testing.T) {
+	cfg := testConfig(t)
+	_, secret, step, _ := enrollTwoFactor(t, cfg)
+	// The code that confirmed enrollment is spent.
+	if code := answerChallenge(t, cfg, startChallenge(t, cfg), totpCode(t, secret, step)); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for the confirmation code, got %d", code)
+	}
+	next := totpCode(t, secret, step+1)
+	if code := answerChallenge(t, cfg, startChallenge(t, cfg), next); code != http.StatusOK {
+		t.Fatalf("expected 200 for a fresh code, got %d", code)
+	}
+	if code := answerChallenge(t, cfg, startChallenge(t, cfg), next); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a replayed code, got %d", code)
+	}
+}
+
+func TestLoginTwoFactorRecoveryCodeWorksOnce(t *testing.T) {
+	cfg := testConfig(t)
+	_, _, _, recoveryCodes := enrollTwoFactor(t, cfg)
+	if code := answerChallenge(t, cfg, startChallenge(t, cfg), recoveryCodes[0]); code != http.StatusOK {
+		t.Fatalf("expected 200 for a recovery code, got %d", code)
+	}
+	if code := answerChallenge(t, cfg, startChallenge(t, cfg), recoveryCodes[0]); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a used recovery code, got %d", code)
+	}
+}
+
+func TestLoginTwoFactorAttemptCap(t *testing.T) {
+	cfg := testConfig(t)
+	_, secret, step, _ := enrollTwoFactor(t, cfg)
+	token := startChallenge(t, cfg)
+	for i := 0; i < maxLoginChallengeAttempts; i++ {
+		// Clear the per-email and per-IP throttles so only the
+		// challenge's own cap is in play.
+		if _, err := cfg.conn.Exec("DELETE FROM login_throttles"); err != nil {
+			t.Fatalf("clearing throttles: %v", err)
+		}
+		if code := answerChallenge(t, cfg, token, "000000"); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, code)
+		}
+	}
+	if code := answerChallenge(t, cfg, token, totpCode(t, secret, step+1)); code != http.StatusUnauthorized {
+		t.Fatalf("expected the challenge to be gone after %d attempts, got %d", maxLoginChallengeAttempts, code)
+	}
+}
+
+func TestLoginTwoFactorThrottledAcrossChallenges(t *testing.T) {
+	cfg := testConfig(t)
+	enrollTwoFactor(t, cfg)
+	// A new challenge every time doesn't get around the throttle on the
+	// account: once the free attempts are used up the password has to
+	// wait too.
+	for i := 0; i <= emailLoginPolicy.FreeAttempts; i++ {
+		if code := answerChallenge(t, cfg, startChallenge(t, cfg), "000000"); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, code)
+		}
+	}
+	credentials := map[string]string{"email": testEmail, "password": testPassword}
+	if rec := serveJSON(t, http.HandlerFunc(cfg.handlerLogin), http.MethodPost, "", credentials, nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after %d wrong codes, got %d", emailLoginPolicy.FreeAttempts+1, rec.Code)
+	}
+}
+
+func TestDisableTwoFactorThrottled(t *testing.T) {
+	cfg := testConfig(t)
+	token, secret, step, _ := enrollTwoFactor(t, cfg)
+	disable := auth.NewMiddleware(cfg.keys, cfg.revocations).Required(http.HandlerFunc(cfg.handlerDisableTwoFactor))
+	for i := 0; i <= emailLoginPolicy.FreeAttempts; i++ {
+		if rec := serveJSON(t, disable, http.MethodDelete, token, map[string]string{"code": "000000"}, nil); rec.Code != http.StatusForbidden {
+			t.Fatalf("attempt %d: expected 403, got %d", i+1, rec.Code)
+		}
+	}
+	// Once the free attempts are used up even the right code has to wait.
+	rec := serveJSON(t, disable, http.MethodDelete, token, map[string]string{"code": totpCode(t, secret, step+1)}, nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after %d wrong codes, got %d", emailLoginPolicy.FreeAttempts+1, rec.Code)
+	}
+}
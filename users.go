@@ -1,7 +1,9 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
@@ -11,13 +13,22 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token,omitempty"`
-	RefreshToken string    `json:"refresh_token,omitempty"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Token         string    `json:"token,omitempty"`
+	RefreshToken  string    `json:"refresh_token,omitempty"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	Handle        string    `json:"handle"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	AvatarURL     string    `json:"avatar_url"`
+	// AvatarMediaID is set instead of AvatarURL when the avatar is one of
+	// the user's uploads.
+	AvatarMediaID *uuid.UUID `json:"avatar_media_id"`
 }
 
 func (cfg *apiConfig) handlerAddUser(w http.ResponseWriter, r *http.Request) {
@@ -30,7 +41,14 @@ func (cfg *apiConfig) handlerAddUser(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusInternalServerError, "Couldn't decode request", err)
 		return
 	}
-	hashedPassword, err := auth.HashPassword(userToCreate.Password)
+	if err := validateEmail(userToCreate.Email); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if !cfg.checkPassword(w, userToCreate.Password, userToCreate.Email) {
+		return
+	}
+	hashedPassword, err := cfg.passwords.Hash(userToCreate.Password)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
@@ -39,16 +57,32 @@ func (cfg *apiConfig) handlerAddUser(w http.ResponseWriter, r *http.Request) {
 		Email:          userToCreate.Email,
 		HashedPassword: hashedPassword,
 	})
+	if uniqueViolation(err) != "" {
+		respondError(w, http.StatusConflict, "Email is already in use", err)
+		return
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Couldn't add user to database", err)
 		return
 	}
+	// The account exists either way; a failed send can be retried with
+	// the resend endpoint.
+	if err = cfg.sendVerificationEmail(r.Context(), u.ID, u.Email); err != nil {
+		log.Printf("Error sending verification email to user %s: %s", u.ID, err)
+	}
 	user := User{
-		ID:          u.ID,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		Email:       u.Email,
-		IsChirpyRed: u.IsChirpyRed,
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		IsChirpyRed:   u.IsChirpyRed,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+		Handle:        u.Handle,
+		DisplayName:   u.DisplayName,
+		Bio:           u.Bio,
+		AvatarURL:     u.AvatarUrl,
+		AvatarMediaID: uuidOrNil(u.AvatarMediaID),
 	}
 	respondWithJSON(w, http.StatusCreated, user)
 }
@@ -63,88 +97,281 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusInternalServerError, "Couldn't decode request", err)
 		return
 	}
+	attempt, wait, err := cfg.startLoginAttempt(r.Context(), loginThrottles(userToLogin.Email, clientIP(r)), clientIP(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking login attempts", err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyLoginAttempts(w, wait)
+		return
+	}
 	user, err := cfg.db.GetUserByEmail(r.Context(), userToLogin.Email)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		// Take as long as a wrong password would.
+		cfg.passwords.Check(userToLogin.Password, cfg.dummyHash)
+		failLogin(w, r, attempt, err)
 		return
 	}
-	if err = auth.CheckPasswordHash(userToLogin.Password, user.HashedPassword); err != nil {
-		respondError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+	needsRehash, err := cfg.passwords.Check(userToLogin.Password, user.HashedPassword)
+	if err != nil {
+		failLogin(w, r, attempt, err)
 		return
 	}
-	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	twoFactor, err := cfg.twoFactorEnabled(r.Context(), user.ID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Error making token", err)
+		respondError(w, http.StatusInternalServerError, "Error checking two-factor authentication", err)
 		return
 	}
-	refreshToken, err := auth.MakeRefreshToken()
+	// With two-factor authentication the password is only half of logging
+	// in, so the failures against the email stay until the code is right
+	// too.
+	if twoFactor {
+		wait, err = attempt.check(r.Context())
+	} else {
+		wait, err = attempt.succeed(r.Context())
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Error making refresh token", err)
+		respondError(w, http.StatusInternalServerError, "Error recording login attempt", err)
 		return
 	}
-	if _, err := cfg.db.MakeRefreshToken(r.Context(), database.MakeRefreshTokenParams{
-		Token:     refreshToken,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().AddDate(0, 0, 60),
-	}); err != nil {
-		respondError(w, http.StatusInternalServerError, "Error adding token to database", err)
+	if wait > 0 {
+		respondTooManyLoginAttempts(w, wait)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, User{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed,
-	})
+	// Only now is the plaintext at hand to move the hash onto the current
+	// algorithm and parameters. Failing to is no reason to refuse a login.
+	if needsRehash {
+		if err = cfg.rehashPassword(r.Context(), user.ID, userToLogin.Password, user.HashedPassword); err != nil {
+			log.Printf("Error rehashing password of user %s: %s", user.ID, err)
+		}
+	}
+	if twoFactor {
+		cfg.startLoginChallenge(w, r, user.ID)
+		return
+	}
+	cfg.completeLogin(w, r, user)
 }
 
-func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+// completeLogin starts a new session for a user who has proven who they are.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, err := auth.MakeJWT(user.ID, user.Role, cfg.keys, accessTokenLifetime)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Error getting token", err)
+		respondError(w, http.StatusInternalServerError, "Error making token", err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	refreshToken, err := issueRefreshToken(r, cfg.db, user.ID, uuid.New())
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Could not validate token", err)
+		respondError(w, http.StatusInternalServerError, "Error making refresh token", err)
 		return
 	}
+	respondWithJSON(w, http.StatusOK, User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Token:         token,
+		RefreshToken:  refreshToken,
+		IsChirpyRed:   user.IsChirpyRed,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified,
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     user.AvatarUrl,
+		AvatarMediaID: uuidOrNil(user.AvatarMediaID),
+	})
+}
+
+// handlerUpdateUser changes the fields present in the request and leaves
+// the rest alone. Changing the email or password takes the current
+// password too, so a stolen access token isn't enough to take over the
+// account; the public profile fields don't need it.
+func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
 
 	decoder := json.NewDecoder(r.Body)
-	credentials := struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+	req := struct {
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+		Handle          *string `json:"handle"`
+		DisplayName     *string `json:"display_name"`
+		Bio             *string `json:"bio"`
+		AvatarURL       *string `json:"avatar_url"`
+		// AvatarMediaID sets the avatar to an upload of the user's.
+		AvatarMediaID *uuid.UUID `json:"avatar_media_id"`
 	}{}
-	if err := decoder.Decode(&credentials); err != nil {
-		respondError(w, http.StatusUnauthorized, "Error getting request", err)
+	if err := decoder.Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Error decoding request", err)
 		return
 	}
-	hashedPassword, err := auth.HashPassword(credentials.Password)
+	current, err := cfg.db.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Error hashing password", err)
+		respondError(w, http.StatusUnauthorized, "Error retrieving user from database", err)
+		return
+	}
+	if req.Email != nil && *req.Email == current.Email {
+		req.Email = nil
+	}
+	email := current.Email
+	if req.Email != nil {
+		if err = validateEmail(*req.Email); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		email = *req.Email
+	}
+	if err = validateProfile(req.Handle, req.DisplayName, req.Bio, req.AvatarURL); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if req.AvatarMediaID != nil {
+		if req.AvatarURL != nil {
+			respondError(w, http.StatusBadRequest, "Set avatar_url or avatar_media_id, not both", nil)
+			return
+		}
+		// Uploads are kept by ID so that avatar_url only ever holds a URL
+		// the user gave. Setting one clears the other.
+		noURL := ""
+		req.AvatarURL = &noURL
+	}
+	if req.Email != nil || req.Password != nil {
+		if !cfg.checkCurrentPassword(w, r, current, req.CurrentPassword) {
+			return
+		}
+	}
+	params := database.UpdateUserParams{
+		ID:          userID,
+		Email:       nullString(req.Email),
+		Handle:      nullString(req.Handle),
+		DisplayName: nullString(req.DisplayName),
+		Bio:         nullString(req.Bio),
+		AvatarUrl:   nullString(req.AvatarURL),
+	}
+	if req.Password != nil {
+		if !cfg.checkPassword(w, *req.Password, email) {
+			return
+		}
+		hashedPassword, err := cfg.passwords.Hash(*req.Password)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error hashing password", err)
+			return
+		}
+		params.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if req.AvatarMediaID != nil {
+		// Locked like media attached to a chirp, so it isn't cleaned up
+		// as unattached before it is the avatar.
+		avatar, err := qtx.GetUserMediaForShare(r.Context(), database.GetUserMediaForShareParams{
+			UserID: userID,
+			Ids:    []uuid.UUID{*req.AvatarMediaID},
+		})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error retrieving avatar media", err)
+			return
+		}
+		if len(avatar) == 0 {
+			respondError(w, http.StatusBadRequest, "Avatar media not found", nil)
+			return
+		}
+		params.AvatarMediaID = uuid.NullUUID{UUID: *req.AvatarMediaID, Valid: true}
+	}
+	newCreds, err := qtx.UpdateUser(r.Context(), params)
+	switch uniqueViolation(err) {
+	case "":
+	case handleConstraint:
+		respondError(w, http.StatusConflict, "Handle is already taken", err)
+		return
+	default:
+		respondError(w, http.StatusConflict, "Email is already in use", err)
 		return
 	}
-	newCreds, err := cfg.db.UpdateUserCredentials(r.Context(), database.UpdateUserCredentialsParams{
-		ID:             userID,
-		Email:          credentials.Email,
-		HashedPassword: hashedPassword,
-	})
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Error updating credentials", err)
+		respondError(w, http.StatusInternalServerError, "Error updating user", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating user", err)
 		return
 	}
+	if req.Email != nil {
+		if err = cfg.sendVerificationEmail(r.Context(), newCreds.ID, newCreds.Email); err != nil {
+			log.Printf("Error sending verification email to user %s: %s", newCreds.ID, err)
+		}
+	}
+	// A new password logs out every existing session.
+	if req.Password != nil && *req.Password != req.CurrentPassword {
+		if err = cfg.signOutEverywhere(r.Context(), userID); err != nil {
+			respondError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+			return
+		}
+	}
 	respondWithJSON(w, http.StatusOK, User{
-		ID:          newCreds.ID,
-		CreatedAt:   newCreds.CreatedAt,
-		UpdatedAt:   newCreds.UpdatedAt,
-		Email:       newCreds.Email,
-		IsChirpyRed: newCreds.IsChirpyRed,
+		ID:            newCreds.ID,
+		CreatedAt:     newCreds.CreatedAt,
+		UpdatedAt:     newCreds.UpdatedAt,
+		Email:         newCreds.Email,
+		IsChirpyRed:   newCreds.IsChirpyRed,
+		Role:          newCreds.Role,
+		EmailVerified: newCreds.EmailVerified,
+		Handle:        newCreds.Handle,
+		DisplayName:   newCreds.DisplayName,
+		Bio:           newCreds.Bio,
+		AvatarURL:     newCreds.AvatarUrl,
+		AvatarMediaID: uuidOrNil(newCreds.AvatarMediaID),
 	})
 }
 
+// checkCurrentPassword responds and returns false unless password is the
+// user's. Wrong guesses count against the same throttles as logins.
+func (cfg *apiConfig) checkCurrentPassword(w http.ResponseWriter, r *http.Request, user database.User, password string) bool {
+	if password == "" {
+		respondError(w, http.StatusBadRequest, "Changing email or password requires current_password", nil)
+		return false
+	}
+	attempt, wait, err := cfg.startLoginAttempt(r.Context(), loginThrottles(user.Email, clientIP(r)), clientIP(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error checking login attempts", err)
+		return false
+	}
+	if wait > 0 {
+		respondTooManyLoginAttempts(w, wait)
+		return false
+	}
+	if _, err = cfg.passwords.Check(password, user.HashedPassword); err != nil {
+		wait, failErr := attempt.fail(r.Context())
+		if failErr != nil {
+			respondError(w, http.StatusInternalServerError, "Error recording login attempt", failErr)
+			return false
+		}
+		if wait > 0 {
+			respondTooManyLoginAttempts(w, wait)
+			return false
+		}
+		respondError(w, http.StatusUnauthorized, "Current password is incorrect", err)
+		return false
+	}
+	// A right password doesn't clear the failures here: the session could
+	// be stolen, and this isn't a login.
+	if wait, err = attempt.check(r.Context()); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error recording login attempt", err)
+		return false
+	}
+	if wait > 0 {
+		respondTooManyLoginAttempts(w, wait)
+		return false
+	}
+	return true
+}
+
 func (cfg *apiConfig) handlerUpgradeUser(w http.ResponseWriter, r *http.Request) {
 	apiKey, err := auth.GetAPIKey(r.Header)
 	if err != nil {
@@ -1,58 +1,170 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
-	"slices"
+	"net/mail"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/snowkittyselene/chirpy/internal/auth"
+	"github.com/snowkittyselene/chirpy/internal/database"
+	"github.com/snowkittyselene/chirpy/internal/profanity"
 )
 
-var badWords = []string{"kerfuffle", "sharbert", "fornax"}
+const maxChirpLength = 140
 
-func removeBadWords(original string) string {
-	goodWords := []string{}
-	words := strings.Fields(original)
-	for _, word := range words {
-		if slices.Contains(badWords, strings.ToLower(word)) {
-			goodWords = append(goodWords, "****")
-		} else {
-			goodWords = append(goodWords, word)
-		}
+// validateChirpBody checks a chirp body against the length limit and the
+// word filter, returning it with any masked words replaced. Only plain
+// rechirps go without a body, and they don't come through here.
+func validateChirpBody(body string, filter profanity.Filter) (profanity.Result, error) {
+	if strings.TrimSpace(body) == "" {
+		return profanity.Result{}, fmt.Errorf("Chirp is empty")
+	}
+	if len(body) > maxChirpLength {
+		return profanity.Result{}, fmt.Errorf("Chirp is too long")
+	}
+	result := filter.Apply(body)
+	if len(result.Rejected) > 0 {
+		return profanity.Result{}, fmt.Errorf("Chirp contains a banned word: %s", strings.Join(result.Rejected, ", "))
+	}
+	return result, nil
+}
+
+// validateEmail accepts a bare address like "user@example.com", without a
+// display name or angle brackets.
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errors.New("Email address is not valid")
 	}
-	return strings.Join(goodWords, " ")
+	return nil
 }
 
+// uniqueViolation returns the constraint err broke if it is Postgres
+// refusing a duplicate value in a unique column, or "" otherwise.
+func uniqueViolation(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func uuidOrNil(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+// issueRefreshToken stores a new refresh token for the user. Every token
+// handed out by rotation stays in the family of the login that started it,
+// and records the client the request came from for the sessions list.
+func issueRefreshToken(r *http.Request, q *database.Queries, userID, familyID uuid.UUID) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	if _, err := q.MakeRefreshToken(r.Context(), database.MakeRefreshTokenParams{
+		Token:     refreshToken,
+		UserID:    userID,
+		ExpiresAt: time.Now().AddDate(0, 0, 60),
+		FamilyID:  familyID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+	}); err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// handlerRefresh swaps a refresh token for a new access token and a new
+// refresh token, revoking the old one. A token that has already been swapped
+// should never be seen again, so if it is, someone else has a copy of it and
+// the whole family it belongs to is revoked.
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondError(w, http.StatusUnauthorized, "Error getting token from headers", err)
 		return
 	}
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), token)
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	stored, err := qtx.GetRefreshTokenForUpdate(r.Context(), token)
 	if err != nil {
 		respondError(w, http.StatusUnauthorized, "Error retrieving user from database", err)
 		return
 	}
-	if user.RevokedAt.Valid {
-		if time.Now().Compare(user.RevokedAt.Time) > -1 {
+	if stored.RotatedAt.Valid {
+		if err = qtx.RevokeTokenFamily(r.Context(), stored.FamilyID); err != nil {
+			respondError(w, http.StatusInternalServerError, "Error revoking tokens", err)
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			respondError(w, http.StatusInternalServerError, "Error revoking tokens", err)
+			return
+		}
+		log.Printf("Refresh token reused for user %s, revoked family %s", stored.UserID, stored.FamilyID)
+		respondError(w, http.StatusUnauthorized, "User token already used, cannot refresh", nil)
+		return
+	}
+	if stored.RevokedAt.Valid {
+		if time.Now().Compare(stored.RevokedAt.Time) > -1 {
 			respondError(w, http.StatusUnauthorized, "User token revoked, cannot refresh", nil)
 			return
 		}
 	}
-	if time.Now().Compare(user.ExpiresAt) > -1 {
+	if time.Now().Compare(stored.ExpiresAt) > -1 {
 		respondError(w, http.StatusUnauthorized, "User token expired, cannot refresh", nil)
 		return
 	}
-	newToken, err := auth.MakeJWT(user.UserID, cfg.secret, time.Hour)
+	user, err := qtx.GetUserByID(r.Context(), stored.UserID)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Error retrieving user from database", err)
+		return
+	}
+	if err = qtx.RotateRefreshToken(r.Context(), token); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error revoking token", err)
+		return
+	}
+	refreshToken, err := issueRefreshToken(r, qtx, user.ID, stored.FamilyID)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Error making new access token", err)
+		respondError(w, http.StatusInternalServerError, "Error making refresh token", err)
+		return
+	}
+	// Sign before committing, so a failure leaves the old refresh token
+	// usable instead of spent with nothing to replace it.
+	newToken, err := auth.MakeJWT(user.ID, user.Role, cfg.keys, accessTokenLifetime)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error making new access token", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error making refresh token", err)
 		return
 	}
 	respondWithJSON(w, http.StatusOK, struct {
-		Token string `json:"token"`
-	}{Token: newToken})
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}{Token: newToken, RefreshToken: refreshToken})
 }
 
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
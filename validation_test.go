@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// logIn signs up a user on the first call and logs them in, returning a
+// refresh token from a new token family.
+func logIn(t *testing.T, cfg *apiConfig) string {
+	t.Helper()
+	credentials := map[string]string{"email": testEmail, "password": testPassword}
+	rec := serveJSON(t, http.HandlerFunc(cfg.handlerAddUser), http.MethodPost, "", credentials, nil)
+	if rec.Code != http.StatusCreated && rec.Code != http.StatusConflict {
+		t.Fatalf("signing up: %d %s", rec.Code, rec.Body)
+	}
+	var user User
+	if rec := serveJSON(t, http.HandlerFunc(cfg.handlerLogin), http.MethodPost, "", credentials, &user); rec.Code != http.StatusOK {
+		t.Fatalf("logging in: %d %s", rec.Code, rec.Body)
+	}
+	return user.RefreshToken
+}
+
+// refresh returns the status of refreshing with token, and the refresh
+// token that replaces it when that worked.
+func refresh(t *testing.T, cfg *apiConfig, token string) (int, string) {
+	t.Helper()
+	var tokens struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	rec := serveJSON(t, http.HandlerFunc(cfg.handlerRefresh), http.MethodPost, token, nil, &tokens)
+	return rec.Code, tokens.RefreshToken
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	cfg := testConfig(t)
+	token := logIn(t, cfg)
+	for range 3 {
+		code, next := refresh(t, cfg, token)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if next == "" || next == token {
+			t.Fatalf("expected a new refresh token, got %q", next)
+		}
+		token = next
+	}
+}
+
+func TestRefreshReplayRevokesFamily(t *testing.T) {
+	cfg := testConfig(t)
+	stolen := logIn(t, cfg)
+	other := logIn(t, cfg)
+	code, latest := refresh(t, cfg, stolen)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code, _ = refresh(t, cfg, stolen); code != http.StatusUnauthorized {
+		t.Fatalf("expected the replayed token to get 401, got %d", code)
+	}
+	// Whoever holds the token that replaced it is logged out as well...
+	if code, _ = refresh(t, cfg, latest); code != http.StatusUnauthorized {
+		t.Fatalf("expected the rest of the family to be revoked, got %d", code)
+	}
+	// ...but the user's other sessions are left alone.
+	if code, _ = refresh(t, cfg, other); code != http.StatusOK {
+		t.Fatalf("expected another family to still refresh, got %d", code)
+	}
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	cfg := testConfig(t)
+	token := logIn(t, cfg)
+	type result struct {
+		code  int
+		token string
+	}
+	results := make(chan result, 10)
+	var wg sync.WaitGroup
+	for range cap(results) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, next := refresh(t, cfg, token)
+			results <- result{code, next}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var issued []string
+	for res := range results {
+		switch res.code {
+		case http.StatusOK:
+			issued = append(issued, res.token)
+		case http.StatusUnauthorized:
+		default:
+			t.Fatalf("expected 200 or 401, got %d", res.code)
+		}
+	}
+	if len(issued) != 1 {
+		t.Fatalf("expected exactly one refresh to go through, got %d", len(issued))
+	}
+	// The others used a spent token, which revokes the family, the one
+	// new token included.
+	if code, _ := refresh(t, cfg, issued[0]); code != http.StatusUnauthorized {
+		t.Fatalf("expected the family to be revoked, got %d", code)
+	}
+}